@@ -0,0 +1,119 @@
+package main
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Access log levels, from quietest
+const (
+	logOff = iota
+	logInfo
+	logDebug
+)
+
+var logLevels = map[string]int{"off": logOff, "info": logInfo, "debug": logDebug}
+
+// Records the status and size of a response
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 && status >= 200 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Access log of one request. The level is the global one (--log-level),
+// unless the matched mock sets its own logLevel, so a single noisy route
+// can be debugged in isolation.
+type accessLog struct {
+	r     *http.Request
+	w     *statusWriter
+	start time.Time
+	level int
+	route string // Route key of the matched mock file
+}
+
+func newAccessLog(w http.ResponseWriter, r *http.Request) (*accessLog, *statusWriter) {
+	sw := &statusWriter{ResponseWriter: w}
+	return &accessLog{r: r, w: sw, start: time.Now(), level: logLevels[configLogLevel]}, sw
+}
+
+// Apply a mock's logLevel
+func (l *accessLog) setLevel(level, filePath string) {
+	if level == "" {
+		return
+	}
+	if lv, ok := logLevels[level]; ok {
+		l.level = lv
+	} else {
+		log.Printf("[WARNING] Unknown logLevel '%s' in %s", level, filePath)
+	}
+}
+
+func (l *accessLog) debugf(format string, args ...interface{}) {
+	if l.level >= logDebug {
+		log.Printf("[DEBUG] %s %s: "+format, append([]interface{}{l.r.Method, l.r.URL.RequestURI()}, args...)...)
+	}
+}
+
+// Record the finished request for the dashboard and log it: Common Log
+// Format at info level, a summary including the matched file and the
+// duration (delays included) at debug
+func (l *accessLog) finish() {
+	status := l.w.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	trafficState.record(trafficEntry{
+		Time:       l.start,
+		Method:     l.r.Method,
+		Path:       l.r.URL.RequestURI(),
+		Route:      l.route,
+		Status:     status,
+		Bytes:      l.w.bytes,
+		DurationMs: float64(time.Since(l.start).Microseconds()) / 1000,
+	})
+	switch l.level {
+	case logInfo:
+		host, _, err := net.SplitHostPort(l.r.RemoteAddr)
+		if err != nil {
+			host = l.r.RemoteAddr
+		}
+		log.Printf("%s - - [%s] \"%s %s %s\" %d %d", host, l.start.Format("02/Jan/2006:15:04:05 -0700"),
+			l.r.Method, l.r.URL.RequestURI(), l.r.Proto, status, l.w.bytes)
+	case logDebug:
+		route := l.route
+		if route == "" {
+			route = "(no mock)"
+		}
+		log.Printf("[DEBUG] %s %s -> %s status=%d bytes=%d duration=%s", l.r.Method, l.r.URL.RequestURI(),
+			route, status, l.w.bytes, time.Since(l.start).Round(time.Microsecond))
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Admin API (under /__apimock/, plus /healthz). Only the read-only
+// endpoints are available unless --admin is set.
+func registerAdminRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		if left := warmupRemaining(configWarmupSeconds); left > 0 {
+			respondJSON(w, 503, map[string]interface{}{"status": "warming", "inFlight": inFlight.Load(), "readyInSeconds": int(left.Seconds() + 0.999)})
+			return
+		}
+		respondJSON(w, 200, map[string]interface{}{"status": "ok", "inFlight": inFlight.Load()})
+	})
+	// Any method, for docker-compose healthchecks and Kubernetes probes
+	mux.HandleFunc("/__apimock/health", func(w http.ResponseWriter, r *http.Request) {
+		mockFiles := 0
+		walkMockFiles(configDir, func(string) { mockFiles++ })
+		health := map[string]interface{}{
+			"status":    "ok",
+			"version":   version,
+			"buildDate": buildDate,
+			"configDir": configDir,
+			"mockFiles": mockFiles,
+		}
+		if left := warmupRemaining(configWarmupSeconds); left > 0 {
+			health["status"] = "warming"
+			health["readyInSeconds"] = int(left.Seconds() + 0.999)
+			respondJSON(w, 503, health)
+			return
+		}
+		respondJSON(w, 200, health)
+	})
+
+	mux.HandleFunc("GET /__apimock/versions", func(w http.ResponseWriter, r *http.Request) {
+		respondJSON(w, 200, versionState.snapshot())
+	})
+	mux.HandleFunc("GET /__apimock/sequences", func(w http.ResponseWriter, r *http.Request) {
+		respondJSON(w, 200, sequenceState.snapshot())
+	})
+	mux.HandleFunc("GET /__apimock/roundrobin", func(w http.ResponseWriter, r *http.Request) {
+		respondJSON(w, 200, roundRobinState.snapshot())
+	})
+	mux.HandleFunc("GET /__apimock/flags", func(w http.ResponseWriter, r *http.Request) {
+		respondJSON(w, 200, flagState.all())
+	})
+	mux.HandleFunc("GET /__apimock/requests", func(w http.ResponseWriter, r *http.Request) {
+		respondJSON(w, 200, trafficState.snapshot())
+	})
+	mux.HandleFunc("GET /__apimock/stats", func(w http.ResponseWriter, r *http.Request) {
+		respondJSON(w, 200, trafficState.stats())
+	})
+	mux.HandleFunc("GET /__apimock/stats/total", func(w http.ResponseWriter, r *http.Request) {
+		respondJSON(w, 200, trafficState.totalStats())
+	})
+
+	// Route ids are mock file paths relative to the mock directory,
+	// with "/" escaped as %2F (e.g. users%2F_.json)
+	mux.HandleFunc("GET /__apimock/routes", func(w http.ResponseWriter, r *http.Request) {
+		routes := []map[string]interface{}{}
+		for _, id := range listRoutes() {
+			routes = append(routes, map[string]interface{}{"id": id, "disabled": isRouteDisabled(id)})
+		}
+		respondJSON(w, 200, routes)
+	})
+
+	// The rest of /__apimock/ is never served by mocks or --upstream
+	mux.HandleFunc("/__apimock/", func(w http.ResponseWriter, r *http.Request) {
+		if !configAdmin {
+			respondJSON(w, 403, map[string]string{"error": "The dashboard and changing state need --admin"})
+			return
+		}
+		if allowed := adminMethods(mux, r); len(allowed) > 0 {
+			w.Header().Set("Allow", strings.Join(allowed, ", "))
+			respondJSON(w, 405, map[string]string{"error": "Method Not Allowed"})
+			return
+		}
+		respondJSON(w, 404, map[string]string{"error": "Not Found"})
+	})
+	if !configAdmin {
+		return
+	}
+
+	mux.HandleFunc("GET /__apimock/ui", serveDashboard)
+
+	mux.HandleFunc("POST /__apimock/versions/advance", func(w http.ResponseWriter, r *http.Request) {
+		route := r.URL.Query().Get("route")
+		respondJSON(w, 200, map[string]interface{}{"route": route, "version": versionState.advance(route)})
+	})
+	mux.HandleFunc("POST /__apimock/versions/set", func(w http.ResponseWriter, r *http.Request) {
+		route := r.URL.Query().Get("route")
+		v, err := strconv.Atoi(r.URL.Query().Get("version"))
+		if err != nil || v < 0 {
+			respondJSON(w, 400, map[string]string{"error": "Invalid version"})
+			return
+		}
+		versionState.set(route, v)
+		respondJSON(w, 200, map[string]interface{}{"route": route, "version": v})
+	})
+	mux.HandleFunc("POST /__apimock/versions/reset", func(w http.ResponseWriter, r *http.Request) {
+		versionState.reset()
+		respondJSON(w, 200, versionState.snapshot())
+	})
+
+	mux.HandleFunc("POST /__apimock/sequences/reset", func(w http.ResponseWriter, r *http.Request) {
+		sequenceState.reset(r.URL.Query().Get("route"))
+		respondJSON(w, 200, sequenceState.snapshot())
+	})
+
+	mux.HandleFunc("POST /__apimock/roundrobin/reset", func(w http.ResponseWriter, r *http.Request) {
+		roundRobinState.reset()
+		respondJSON(w, 200, roundRobinState.snapshot())
+	})
+
+	// Body: {"NAME": value, ...}
+	mux.HandleFunc("POST /__apimock/flags", func(w http.ResponseWriter, r *http.Request) {
+		var flags map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&flags); err != nil {
+			respondJSON(w, 400, map[string]string{"error": "Invalid JSON"})
+			return
+		}
+		flagState.set(flags)
+		respondJSON(w, 200, flagState.all())
+	})
+	mux.HandleFunc("POST /__apimock/flags/reset", func(w http.ResponseWriter, r *http.Request) {
+		flagState.reset()
+		respondJSON(w, 200, flagState.all())
+	})
+
+	mux.HandleFunc("POST /__apimock/clients/reset", func(w http.ResponseWriter, r *http.Request) {
+		clientState.reset()
+		respondJSON(w, 200, map[string]string{"status": "ok"})
+	})
+
+	mux.HandleFunc("POST /__apimock/requests/reset", func(w http.ResponseWriter, r *http.Request) {
+		trafficState.reset()
+		respondJSON(w, 200, trafficState.snapshot())
+	})
+	mux.HandleFunc("POST /__apimock/stats/reset", func(w http.ResponseWriter, r *http.Request) {
+		trafficState.resetStats()
+		respondJSON(w, 200, trafficState.stats())
+	})
+
+	// Reset all state kept by requests and the admin API
+	mux.HandleFunc("POST /__apimock/reset", func(w http.ResponseWriter, r *http.Request) {
+		sequenceState.reset("")
+		versionState.reset()
+		roundRobinState.reset()
+		flagState.reset()
+		clientState.reset()
+		trafficState.reset()
+		resetRateLimits()
+		resetDisabledRoutes()
+		resetResponseCache()
+		respondJSON(w, 200, map[string]string{"status": "ok"})
+	})
+
+	mux.HandleFunc("POST /__apimock/routes/{id}/disable", func(w http.ResponseWriter, r *http.Request) {
+		setRouteDisabled(r.PathValue("id"), true)
+		respondJSON(w, 200, map[string]interface{}{"id": r.PathValue("id"), "disabled": true})
+	})
+	mux.HandleFunc("POST /__apimock/routes/{id}/enable", func(w http.ResponseWriter, r *http.Request) {
+		setRouteDisabled(r.PathValue("id"), false)
+		respondJSON(w, 200, map[string]interface{}{"id": r.PathValue("id"), "disabled": false})
+	})
+}
+
+// Methods an admin endpoint other than the catch-all accepts for the
+// request's path (none if the path is unknown)
+func adminMethods(mux *http.ServeMux, r *http.Request) []string {
+	var allowed []string
+	for _, method := range []string{"GET", "POST", "PUT", "PATCH", "DELETE"} {
+		probe := r.Clone(r.Context())
+		probe.Method = method
+		if _, pattern := mux.Handler(probe); pattern != "/__apimock/" {
+			allowed = append(allowed, method)
+		}
+	}
+	return allowed
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAdminRoutesNeedAdmin(t *testing.T) {
+	tests := []struct {
+		method, target string
+		off, on        int
+	}{
+		{"GET", "/__apimock/health", 200, 200},
+		{"GET", "/__apimock/flags", 200, 200},
+		{"GET", "/__apimock/routes", 200, 200},
+		{"GET", "/__apimock/ui", 403, 200},
+		{"POST", "/__apimock/flags/reset", 403, 200},
+		{"POST", "/__apimock/reset", 403, 200},
+		{"POST", "/__apimock/routes/users.json/disable", 403, 200},
+		{"POST", "/__apimock/unknown", 403, 404},
+		{"GET", "/__apimock/typo", 403, 404},
+		{"GET", "/__apimock/reset", 403, 405},
+		{"DELETE", "/__apimock/flags", 403, 405},
+	}
+	for _, admin := range []bool{false, true} {
+		newMockDir(t, map[string]string{
+			"users.json":          `{"body": []}`,
+			"__apimock/typo.json": `{"body": {"mock": true}}`,
+		})
+		setConfig(t, &configAdmin, admin)
+		mux := http.NewServeMux()
+		registerAdminRoutes(mux)
+		mux.HandleFunc("/", mockHandler)
+		for _, tt := range tests {
+			want := tt.off
+			if admin {
+				want = tt.on
+			}
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, newRequest(tt.method, tt.target, ""))
+			if rec.Code != want {
+				t.Errorf("admin=%v: %s %s = %d, want %d", admin, tt.method, tt.target, rec.Code, want)
+			}
+			if want == 405 && rec.Header().Get("Allow") == "" {
+				t.Errorf("admin=%v: %s %s: no Allow header", admin, tt.method, tt.target)
+			}
+		}
+		setRouteDisabled("users.json", false)
+	}
+}
+
+func TestAdminResetClearsState(t *testing.T) {
+	newMockDir(t, map[string]string{
+		"limited.json": `{"rateLimit": {"requests": 1, "window": 60}, "body": {"ok": true}}`,
+		"users.json":   `{"body": []}`,
+	})
+	setConfig(t, &configAdmin, true)
+	mux := http.NewServeMux()
+	registerAdminRoutes(mux)
+	mux.HandleFunc("/", mockHandler)
+	do := func(method, target string) int {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, newRequest(method, target, ""))
+		return rec.Code
+	}
+	resetRateLimits()
+	t.Cleanup(resetRateLimits)
+
+	do("GET", "/limited")
+	setRouteDisabled("users.json", true)
+	if do("GET", "/limited") != 429 || do("GET", "/users") == 200 {
+		t.Fatal("rate limit or disabled route not in effect before the reset")
+	}
+	if code := do("POST", "/__apimock/reset"); code != 200 {
+		t.Fatalf("POST /__apimock/reset = %d", code)
+	}
+	if code := do("GET", "/limited"); code != 200 {
+		t.Errorf("rate-limited route after the reset = %d, want 200", code)
+	}
+	if code := do("GET", "/users"); code != 200 {
+		t.Errorf("disabled route after the reset = %d, want 200", code)
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Temporary directory a mock archive was extracted to (removed on exit)
+var archiveDir string
+
+// The .zip file or URL the mock directory was configured as
+var archiveSource string
+
+// Upper bound on both the archive and its extracted contents
+var maxArchiveSize int64 = 1 << 30
+
+// Whether the mock directory is a .zip file or an http(s) URL of one
+func isMockArchive(dir string) bool {
+	if strings.HasPrefix(dir, "http://") || strings.HasPrefix(dir, "https://") {
+		return true
+	}
+	info, err := os.Stat(dir)
+	return err == nil && info.Mode().IsRegular() && strings.EqualFold(filepath.Ext(dir), ".zip")
+}
+
+// Extract a mock archive (a local path or URL) to a temporary directory
+// and return the directory to serve: the archive's single top-level
+// directory if it has one, else the extraction root
+func extractMockArchive(source string) (string, error) {
+	var data []byte
+	var err error
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		data, err = downloadArchive(source)
+	} else {
+		data, err = os.ReadFile(source)
+	}
+	if err != nil {
+		return "", err
+	}
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", err
+	}
+
+	archiveSource = source
+	archiveDir, err = os.MkdirTemp("", "apimock-")
+	if err != nil {
+		return "", err
+	}
+	remaining := maxArchiveSize
+	for _, f := range zr.File {
+		if err := extractZipFile(f, archiveDir, &remaining); err != nil {
+			return "", err
+		}
+	}
+
+	entries, err := os.ReadDir(archiveDir)
+	if err != nil {
+		return "", err
+	}
+	if len(entries) == 1 && entries[0].IsDir() {
+		return filepath.Join(archiveDir, entries[0].Name()), nil
+	}
+	return archiveDir, nil
+}
+
+func downloadArchive(url string) ([]byte, error) {
+	client := &http.Client{Timeout: 60 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxArchiveSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxArchiveSize {
+		return nil, fmt.Errorf("GET %s: archive larger than %d bytes", url, maxArchiveSize)
+	}
+	return data, nil
+}
+
+// Write one archive entry under root, refusing paths that leave it and
+// contents beyond the remaining byte budget
+func extractZipFile(f *zip.File, root string, remaining *int64) error {
+	name := filepath.FromSlash(f.Name)
+	if !filepath.IsLocal(name) {
+		return fmt.Errorf("invalid path in archive: %s", f.Name)
+	}
+	target := filepath.Join(root, name)
+	if f.FileInfo().IsDir() {
+		return os.MkdirAll(target, 0755)
+	}
+	if !f.Mode().IsRegular() {
+		return nil // Symlinks and other special files are skipped
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+
+	src, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(dst, io.LimitReader(src, *remaining+1))
+	if err != nil {
+		dst.Close()
+		return err
+	}
+	if *remaining -= n; *remaining < 0 {
+		dst.Close()
+		return fmt.Errorf("archive extracts to more than %d bytes", maxArchiveSize)
+	}
+	return dst.Close()
+}
+
+// Exit after removing the extracted archive: deferred calls do not run
+// on os.Exit (nor on log.Fatal)
+func exit(code int) {
+	removeMockArchive()
+	os.Exit(code)
+}
+
+// log.Fatalf that removes the extracted archive first
+func fatalf(format string, v ...interface{}) {
+	removeMockArchive()
+	log.Fatalf(format, v...)
+}
+
+// Remove the extracted archive, if any
+func removeMockArchive() {
+	if archiveDir == "" {
+		return
+	}
+	if err := os.RemoveAll(archiveDir); err != nil {
+		log.Printf("[WARNING] Failed to remove %s: %v", archiveDir, err)
+	}
+	archiveDir = ""
+}
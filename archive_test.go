@@ -0,0 +1,112 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Zip archive holding files (path -> content)
+func newZip(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestExtractMockArchiveURL(t *testing.T) {
+	data := newZip(t, map[string]string{"mock/users.json": `{"body": []}`})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(data)
+	}))
+	defer srv.Close()
+	t.Cleanup(removeMockArchive)
+	setConfig(t, &archiveSource, "")
+
+	dir, err := extractMockArchive(srv.URL + "/mock.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Base(dir) != "mock" {
+		t.Errorf("dir = %s, want the single top-level directory", dir)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "users.json")); err != nil {
+		t.Error(err)
+	}
+
+	extracted := archiveDir
+	removeMockArchive()
+	if _, err := os.Stat(extracted); !os.IsNotExist(err) {
+		t.Errorf("%s still exists after removeMockArchive", extracted)
+	}
+}
+
+func TestExtractMockArchiveTooLarge(t *testing.T) {
+	data := newZip(t, map[string]string{"users.json": strings.Repeat("x", 4096)})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(data)
+	}))
+	defer srv.Close()
+	t.Cleanup(removeMockArchive)
+	setConfig(t, &archiveSource, "")
+
+	// The download itself exceeds the cap
+	setConfig(t, &maxArchiveSize, int64(len(data)-1))
+	if _, err := extractMockArchive(srv.URL); err == nil || !strings.Contains(err.Error(), "larger than") {
+		t.Errorf("download over the cap: err = %v", err)
+	}
+
+	// The archive fits but its contents do not
+	maxArchiveSize = int64(len(data))
+	path := filepath.Join(t.TempDir(), "mock.zip")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := extractMockArchive(path); err == nil || !strings.Contains(err.Error(), "more than") {
+		t.Errorf("extraction over the cap: err = %v", err)
+	}
+}
+
+func TestWarnConfigChangedArchive(t *testing.T) {
+	newMockDir(t, nil)
+	path := filepath.Join(t.TempDir(), "mock.zip")
+	if err := os.WriteFile(path, newZip(t, map[string]string{"users.json": `{}`}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rc := `{"dir": "` + filepath.ToSlash(path) + `"}`
+	if err := os.WriteFile(filepath.Join(os.Getenv("HOME"), ".apimockrc"), []byte(rc), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(removeMockArchive)
+	setConfig(t, &archiveSource, "")
+	dir, err := extractMockArchive(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setConfig(t, &configDir, dir)
+	setConfig(t, &configPorts, []listenPort{{Port: "8080"}})
+
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+	warnConfigChanged()
+	if strings.Contains(buf.String(), "dir is now") {
+		t.Errorf("unchanged archive dir reported as changed: %s", buf.String())
+	}
+}
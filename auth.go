@@ -0,0 +1,78 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Credentials a request must send in the Authorization header. "auth":
+// false turns off the global auth of .apimockrc for a mock.
+type Auth struct {
+	Username string `json:"username"` // Basic
+	Password string `json:"password"`
+	Token    string `json:"token"` // Bearer
+	Realm    string `json:"realm"` // Default: "apimock"
+	disabled bool
+}
+
+func (a *Auth) UnmarshalJSON(data []byte) error {
+	if string(data) == "false" {
+		a.disabled = true
+		return nil
+	}
+	type plain Auth
+	if err := json.Unmarshal(data, (*plain)(a)); err != nil {
+		return fmt.Errorf("auth must be an object or false")
+	}
+	if a.Username == "" && a.Token == "" {
+		return fmt.Errorf("auth needs a username (Basic) or a token (Bearer)")
+	}
+	return nil
+}
+
+// Check the Authorization header against the credentials. Writes 401 with
+// WWW-Authenticate and returns false if they are missing or wrong.
+func checkAuth(w http.ResponseWriter, r *http.Request, a *Auth) bool {
+	if a == nil || a.disabled {
+		return true
+	}
+	scheme, credentials, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+	credentials = strings.TrimSpace(credentials)
+	invalidToken := false
+	switch {
+	case a.Username != "" && strings.EqualFold(scheme, "Basic"):
+		if user, pass, ok := r.BasicAuth(); ok && secretEqual(user, a.Username) && secretEqual(pass, a.Password) {
+			return true
+		}
+	case a.Token != "" && strings.EqualFold(scheme, "Bearer"):
+		if secretEqual(credentials, a.Token) {
+			return true
+		}
+		invalidToken = true
+	}
+
+	realm := a.Realm
+	if realm == "" {
+		realm = "apimock"
+	}
+	if a.Username != "" {
+		w.Header().Add("WWW-Authenticate", fmt.Sprintf("Basic realm=%q", realm))
+	}
+	if a.Token != "" {
+		challenge := fmt.Sprintf("Bearer realm=%q", realm)
+		if invalidToken {
+			challenge += `, error="invalid_token"`
+		}
+		w.Header().Add("WWW-Authenticate", challenge)
+	}
+	respondJSON(w, 401, map[string]string{"error": "Unauthorized"})
+	return false
+}
+
+// Compare secrets in constant time
+func secretEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Content types of common bodyFile extensions that are not in Go's
+// built-in table, so they do not depend on the system's mime.types
+func init() {
+	for ext, ct := range map[string]string{
+		".csv":  "text/csv; charset=utf-8",
+		".txt":  "text/plain; charset=utf-8",
+		".md":   "text/markdown; charset=utf-8",
+		".yaml": "application/yaml",
+		".yml":  "application/yaml",
+	} {
+		mime.AddExtensionType(ext, ct)
+	}
+}
+
+// Path of a bodyFile (relative to configDir). Symlinks are resolved, so
+// neither .. nor a link can point outside of configDir.
+func bodyFilePath(name string) (string, error) {
+	clean := path.Clean("/" + filepath.ToSlash(name))
+	if clean == "/" {
+		return "", fmt.Errorf("invalid bodyFile '%s'", name)
+	}
+	root, err := filepath.EvalSymlinks(configDir)
+	if err != nil {
+		return "", err
+	}
+	p, err := filepath.EvalSymlinks(filepath.Join(root, filepath.FromSlash(clean)))
+	if err != nil {
+		return "", err
+	}
+	if rel, err := filepath.Rel(root, p); err != nil || !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("bodyFile '%s' is outside the mock directory", name)
+	}
+	return p, nil
+}
+
+// Content-Type of a bodyFile from its extension, else sniffed from the
+// start of the file
+func bodyFileContentType(p string) (string, error) {
+	if ct := mime.TypeByExtension(filepath.Ext(p)); ct != "" {
+		return ct, nil
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	return http.DetectContentType(head[:n]), nil
+}
+
+// Whether a header refers to the response body ({body.length}, ...),
+// which then has to be read into memory
+func headersUseBody(headers map[string]string) bool {
+	for _, v := range headers {
+		if strings.Contains(v, "{body.") {
+			return true
+		}
+	}
+	return false
+}
+
+// Stream a bodyFile that is sent as is, without reading it into memory
+func writeBodyFile(w http.ResponseWriter, r *http.Request, status int, p string) {
+	f, err := os.Open(p)
+	if err != nil {
+		respondError(w, r, 500, map[string]string{"error": fmt.Sprintf("bodyFile failed: %v", err)})
+		return
+	}
+	defer f.Close()
+	if info, err := f.Stat(); err == nil {
+		w.Header().Set("Content-Length", strconv.FormatInt(info.Size(), 10))
+	}
+	w.WriteHeader(status)
+	io.Copy(w, f)
+}
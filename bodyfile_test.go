@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestBodyFile(t *testing.T) {
+	png := "\x89PNG\r\n\x1a\n{path.0}\x00\xff"
+	dir := newMockDir(t, map[string]string{
+		"reports/_.json":    `{"bodyFile": "files/report.csv"}`,
+		"logo.json":         `{"bodyFile": "files/logo.png"}`,
+		"logo/hashed.json":  `{"bodyFile": "files/logo.png", "headers": {"X-Length": "{body.length}"}}`,
+		"escape.json":       `{"bodyFile": "../../etc/passwd"}`,
+		"linked.json":       `{"bodyFile": "files/linked.txt"}`,
+		"files/report.csv":  "id,owner\n1,{path.0}\n",
+		"files/logo.png":    png,
+		"../outside/secret": "secret",
+	})
+	if err := os.Symlink(filepath.Join(dir, "..", "outside", "secret"), filepath.Join(dir, "files", "linked.txt")); err != nil {
+		t.Skip("symlinks not supported:", err)
+	}
+
+	rec := serve(t, newRequest("GET", "/reports/7", ""))
+	if rec.Code != 200 || rec.Body.String() != "id,owner\n1,7\n" || rec.Header().Get("Content-Type") != "text/csv; charset=utf-8" {
+		t.Errorf("CSV bodyFile = %d %q (%s)", rec.Code, rec.Body.String(), rec.Header().Get("Content-Type"))
+	}
+
+	rec = serve(t, newRequest("GET", "/logo", ""))
+	if rec.Code != 200 || rec.Body.String() != png || rec.Header().Get("Content-Type") != "image/png" {
+		t.Errorf("PNG bodyFile = %d %q (%s), want the file byte for byte", rec.Code, rec.Body.String(), rec.Header().Get("Content-Type"))
+	}
+	if rec.Header().Get("Content-Length") != strconv.Itoa(len(png)) {
+		t.Errorf("Content-Length = %q, want %d", rec.Header().Get("Content-Length"), len(png))
+	}
+
+	rec = serve(t, newRequest("GET", "/logo/hashed", ""))
+	if rec.Body.String() != png || rec.Header().Get("X-Length") != strconv.Itoa(len(png)) {
+		t.Errorf("bodyFile with {body.length} = %q, X-Length %q", rec.Body.String(), rec.Header().Get("X-Length"))
+	}
+
+	for _, target := range []string{"/escape", "/linked"} {
+		rec = serve(t, newRequest("GET", target, ""))
+		if rec.Code != 500 || strings.Contains(rec.Body.String(), "secret") || strings.Contains(rec.Body.String(), "root:") {
+			t.Errorf("GET %s = %d %q, want 500 without the file", target, rec.Code, rec.Body.String())
+		}
+	}
+}
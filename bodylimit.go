@@ -0,0 +1,46 @@
+package main
+
+import (
+	"io"
+	"net/http"
+)
+
+// Request body limited by maxRequestBody
+type limitedBody struct {
+	io.ReadCloser
+	limit int64
+}
+
+// Maximum size of the request body read for matching (maxBodySize unless
+// a maxRequestBody limit applies to the request)
+func bodyLimit(r *http.Request) int64 {
+	if b, ok := r.Body.(limitedBody); ok {
+		return b.limit
+	}
+	return maxBodySize
+}
+
+// Enforce a maxRequestBody limit (0: none). A declared Content-Length over
+// the limit is rejected without reading the body; a chunked body is read
+// (and kept for matching) to find out its size, unless the mock rejects
+// Expect: 100-continue.
+func checkBodyLimit(w http.ResponseWriter, r *http.Request, mock MockResponse) bool {
+	limit := configMaxRequestBody
+	if mock.MaxRequestBody > 0 {
+		limit = mock.MaxRequestBody
+	}
+	if limit <= 0 {
+		return true
+	}
+	if r.ContentLength > limit {
+		respondBodyTooLarge(w, &http.MaxBytesError{Limit: limit})
+		return false
+	}
+	r.Body = limitedBody{http.MaxBytesReader(w, r.Body, limit), limit}
+	if r.ContentLength < 0 && !(mock.Continue == "reject" && expectsContinue(r)) {
+		if _, err := readBody(r); respondBodyTooLarge(w, err) {
+			return false
+		}
+	}
+	return true
+}
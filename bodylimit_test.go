@@ -0,0 +1,53 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestBodyLimit(t *testing.T) {
+	newMockDir(t, map[string]string{
+		"small.json":  `{"method": ["POST"], "body": {"ok":true}}`,
+		"upload.json": `{"method": ["POST"], "maxRequestBody": 64, "body": {"uploaded":true}}`,
+	})
+	setConfig(t, &configMaxRequestBody, 16)
+	big := strings.Repeat("x", 32)
+
+	tests := []struct {
+		name, target, body string
+		chunked            bool
+		want               int
+	}{
+		{"within the global limit", "/small", "tiny", false, 200},
+		{"Content-Length over the global limit", "/small", big, false, 413},
+		{"chunked body over the global limit", "/small", big, true, 413},
+		{"per-mock limit raises the global one", "/upload", big, false, 200},
+		{"Content-Length over the per-mock limit", "/upload", big + big + big, false, 413},
+		{"chunked body over the per-mock limit", "/upload", big + big + big, true, 413},
+	}
+	for _, tt := range tests {
+		req := newRequest("POST", tt.target, tt.body)
+		if tt.chunked {
+			req.ContentLength = -1
+			req.Body = io.NopCloser(strings.NewReader(tt.body))
+		}
+		if rec := serve(t, req); rec.Code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, rec.Code, tt.want)
+		}
+	}
+}
+
+func TestBodyTooLargeResponse(t *testing.T) {
+	newMockDir(t, map[string]string{"items.json": `{"method": ["POST"], "body": {}}`})
+	setConfig(t, &configMaxRequestBody, 4)
+	setConfig(t, &configBodyTooLargeResponse, &ErrorResponse{Body: []byte(`{"max":{limit}}`)})
+
+	rec := serve(t, newRequest("POST", "/items", "too large"))
+	if rec.Code != 413 || rec.Body.String() != `{"max":4}` {
+		t.Errorf("got %d %q, want 413 with the custom body", rec.Code, rec.Body.String())
+	}
+	if rec.Header().Get("Connection") != "close" {
+		t.Errorf("Connection = %q, want close", rec.Header().Get("Connection"))
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Directory of shared bodies, relative to configDir. Not routable.
+const bodiesDir = "_bodies"
+
+type sharedBody struct {
+	modTime time.Time
+	body    json.RawMessage
+}
+
+// Shared bodies by name (re-read when the file changes)
+var (
+	bodiesMu    sync.Mutex
+	bodiesCache = map[string]*sharedBody{}
+)
+
+// Body registered as _bodies/<name>.json. Every mock referring to the
+// name shares one copy, which is only read again when the file changes.
+func loadBodyRef(name string) (json.RawMessage, error) {
+	clean := path.Clean("/" + name)[1:]
+	if clean == "" || clean != name || strings.HasPrefix(name, ".") {
+		return nil, fmt.Errorf("invalid bodyRef '%s'", name)
+	}
+	file := filepath.Join(configDir, bodiesDir, filepath.FromSlash(name)+".json")
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil, err
+	}
+
+	bodiesMu.Lock()
+	defer bodiesMu.Unlock()
+	if b, ok := bodiesCache[name]; ok && b.modTime.Equal(info.ModTime()) {
+		return b.body, nil
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if !json.Valid(data) {
+		return nil, fmt.Errorf("%s is not valid JSON", file)
+	}
+	bodiesCache[name] = &sharedBody{modTime: info.ModTime(), body: data}
+	return data, nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+type listingEntry struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+	Type string `json:"type"` // "dir" or "mock"
+}
+
+// Serve a listing of the routes below a directory of the request's mock
+// directory (baseDir). Returns false if requestPath is not a directory.
+func serveListing(w http.ResponseWriter, r *http.Request, baseDir, requestPath string) bool {
+	urlPath := path.Clean("/" + requestPath) // Also prevents escaping baseDir
+	dir := filepath.Join(baseDir, filepath.FromSlash(urlPath))
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return false
+	}
+
+	base := strings.TrimSuffix(urlPath, "/") + "/"
+	routes := []listingEntry{}
+	seen := map[string]bool{} // users.GET.json and users.POST.json are one route
+	for _, e := range entries {
+		// Ignore patterns are relative to configDir, like for routing
+		rel, _ := filepath.Rel(configDir, filepath.Join(dir, e.Name()))
+		if isIgnored(filepath.ToSlash(rel), e.IsDir()) {
+			continue
+		}
+		if e.IsDir() {
+			routes = append(routes, listingEntry{Name: e.Name() + "/", Path: base + e.Name() + "/", Type: "dir"})
+		} else if name, _ := splitMockName(e.Name()); isMockFile(e.Name()) && name != "index" && !strings.Contains(e.Name(), queryLabelSep) && !seen[name] {
+			seen[name] = true
+			routes = append(routes, listingEntry{Name: name, Path: base + name, Type: "mock"})
+		}
+	}
+
+	if !strings.Contains(r.Header.Get("Accept"), "text/html") {
+		respondJSON(w, 200, map[string]interface{}{"path": base, "routes": routes})
+		return true
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(200)
+	fmt.Fprintf(w, "<!DOCTYPE html>\n<html><head><title>apimock: %s</title></head><body>\n", html.EscapeString(base))
+	fmt.Fprintf(w, "<h1>%s</h1>\n<ul>\n", html.EscapeString(base))
+	if base != "/" {
+		fmt.Fprintf(w, "<li><a href=\"%s\">../</a></li>\n", html.EscapeString(path.Dir(strings.TrimSuffix(base, "/"))))
+	}
+	for _, e := range routes {
+		fmt.Fprintf(w, "<li><a href=\"%s\">%s</a></li>\n", html.EscapeString(e.Path), html.EscapeString(e.Name))
+	}
+	fmt.Fprint(w, "</ul>\n</body></html>\n")
+	return true
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestListingUsesRequestDir(t *testing.T) {
+	dir := newMockDir(t, map[string]string{
+		"users.json":              `{"body": []}`,
+		"hosts/api/orders.json":   `{"body": []}`,
+		"hosts/api/v1/items.json": `{"body": []}`,
+		"admin/stats.json":        `{"body": {}}`,
+	})
+	setConfig(t, browse, true)
+	setConfig(t, &configHostRouting, true)
+
+	rec := serve(t, newRequest("GET", "http://api.example.com/", ""))
+	if body := rec.Body.String(); !strings.Contains(body, `"/orders"`) || strings.Contains(body, "users") {
+		t.Errorf("listing for api.example.com = %s, want the routes of hosts/api", body)
+	}
+	rec = serve(t, newRequest("GET", "http://api.example.com/v1", ""))
+	if body := rec.Body.String(); !strings.Contains(body, `"/v1/items"`) {
+		t.Errorf("listing of /v1 for api.example.com = %s, want hosts/api/v1", body)
+	}
+
+	setConfig(t, &configHostRouting, false)
+	rec = httptest.NewRecorder()
+	withPortDir(filepath.Join(dir, "admin"), http.HandlerFunc(mockHandler)).ServeHTTP(rec, newRequest("GET", "/", ""))
+	if body := rec.Body.String(); !strings.Contains(body, `"/stats"`) || strings.Contains(body, "users") {
+		t.Errorf("listing for the admin port = %s, want the routes of admin/", body)
+	}
+}
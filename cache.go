@@ -0,0 +1,64 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type cacheEntry struct {
+	res     renderedResponse
+	expires time.Time
+}
+
+// Rendered responses of mocks with cacheTTL
+var (
+	cacheMu       sync.Mutex
+	responseCache = map[string]cacheEntry{}
+)
+
+func resetResponseCache() {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	responseCache = map[string]cacheEntry{}
+}
+
+// The cache key is the request path, the query string (parameters sorted)
+// and a hash of the selected response definition, so editing the mock
+// file (or selecting another variant/version) never hits a stale entry.
+func responseCacheKey(mock MockResponse, r *http.Request) string {
+	def, _ := json.Marshal(mock)
+	sum := sha256.Sum256(def)
+	return r.URL.Path + "?" + r.URL.Query().Encode() + "#" + hex.EncodeToString(sum[:])
+}
+
+func renderCached(mock MockResponse, filePath string, r *http.Request, pathParams []string) renderedResponse {
+	key := responseCacheKey(mock, r)
+	now := time.Now()
+
+	cacheMu.Lock()
+	entry, ok := responseCache[key]
+	cacheMu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.res
+	}
+
+	res := renderResponse(mock, filePath, r, pathParams)
+	if res.err != nil {
+		return res
+	}
+
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	// Drop expired entries so the cache does not grow forever
+	for k, e := range responseCache {
+		if !now.Before(e.expires) {
+			delete(responseCache, k)
+		}
+	}
+	responseCache[key] = cacheEntry{res: res, expires: now.Add(time.Duration(mock.CacheTTL) * time.Second)}
+	return res
+}
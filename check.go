@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+)
+
+// JSON field names of MockResponse
+var mockFields = func() map[string]bool {
+	fields := map[string]bool{}
+	t := reflect.TypeOf(MockResponse{})
+	for i := 0; i < t.NumField(); i++ {
+		if name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ","); name != "" && name != "-" {
+			fields[name] = true
+		}
+	}
+	return fields
+}()
+
+// Report unknown fields in a mock file. Files without any known field are
+// simple mode files (the whole file is the body) and are not checked.
+func unknownFieldError(data []byte) error {
+	var top map[string]json.RawMessage
+	if json.Unmarshal(data, &top) != nil {
+		return nil
+	}
+	if !hasMockField(top) {
+		return nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var mock MockResponse
+	if err := dec.Decode(&mock); err != nil && strings.Contains(err.Error(), "unknown field") {
+		return err
+	}
+	return nil
+}
+
+// Whether a mock file is valid JSON without any mock field, which is
+// served as the body itself (simple mode)
+func isSimpleModeBody(data []byte) bool {
+	if !json.Valid(data) {
+		return false
+	}
+	var obj map[string]json.RawMessage
+	return json.Unmarshal(data, &obj) != nil || !hasMockField(obj)
+}
+
+// Problem of a mock file: not parseable, fields of the wrong type, or
+// unknown fields with --strict-fields (nil if it is fine)
+func checkMockFile(path string) error {
+	if err := checkSegmentRegexps(path); err != nil {
+		return err
+	}
+	data, err := readMockFile(path)
+	if err != nil {
+		return err
+	}
+	var top interface{}
+	if err := json.Unmarshal(data, &top); err != nil {
+		return err
+	}
+	if !isSimpleModeBody(data) {
+		var mock MockResponse
+		if err := json.Unmarshal(data, &mock); err != nil {
+			return err
+		}
+		if err := checkTemplates(mock); err != nil {
+			return err
+		}
+	}
+	if *strictFields {
+		return unknownFieldError(data)
+	}
+	return nil
+}
+
+// Problem of a _defaults file (nil if it is fine)
+func checkDefaultsFile(path string) error {
+	data, err := readMockFile(path)
+	if err != nil {
+		return err
+	}
+	var defaults map[string]json.RawMessage
+	if json.Unmarshal(data, &defaults) != nil {
+		return fmt.Errorf("must be an object of mock fields")
+	}
+	var mock MockResponse
+	if err := json.Unmarshal(data, &mock); err != nil {
+		return err
+	}
+	if *strictFields {
+		return unknownFieldError(data)
+	}
+	return nil
+}
+
+// Check every mock and _defaults file, calling report for each problem
+func checkAllFiles(report func(path string, err error)) (files, problems int) {
+	check := func(path string, err error) {
+		files++
+		if err != nil {
+			report(path, err)
+			problems++
+		}
+	}
+	walkMockFiles(configDir, func(path string) {
+		if !isGzipFixture(path) {
+			check(path, checkMockFile(path))
+		}
+	})
+	walkFiles(configDir, isDefaultsFile, func(path string) {
+		check(path, checkDefaultsFile(path))
+	})
+	return files, problems
+}
+
+// Validate every mock file and exit (non-zero if any problem was found)
+func runCheck() {
+	files, problems := checkAllFiles(func(path string, err error) {
+		fmt.Printf("[ERROR] %s: %v\n", path, err)
+	})
+	if problems > 0 {
+		fmt.Printf("%d problem(s) found in %d mock file(s)\n", problems, files)
+		exit(1)
+	}
+	fmt.Printf("All %d mock file(s) OK\n", files)
+}
+
+// Validate every mock file at startup and log the problems. With strict,
+// any problem stops the server.
+func validateMockFiles(strict bool) {
+	files, problems := checkAllFiles(func(path string, err error) {
+		log.Printf("[WARNING] %s: %v", path, err)
+	})
+	if problems == 0 {
+		log.Printf("[apimock] Validated %d mock file(s)", files)
+		return
+	}
+	if strict {
+		fatalf("%d problem(s) found in %d mock file(s). Fix them or start without --strict.", problems, files)
+	}
+	log.Printf("[WARNING] %d problem(s) found in %d mock file(s)", problems, files)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Requests seen per route and client, for the firstRequest matcher
+type clientVisit struct {
+	count int
+	last  time.Time
+}
+
+type clientStore struct {
+	mu        sync.Mutex
+	visits    map[string]*clientVisit // "route\x00client"
+	lastSweep time.Time
+}
+
+var clientState = &clientStore{visits: map[string]*clientVisit{}}
+
+// Count a request and return how many requests the client made to the
+// route before it. With configClientExpiry, a client that has been idle
+// for longer is counted as new again.
+func (s *clientStore) visit(route, client string) int {
+	key := route + "\x00" + client
+	now := time.Now()
+
+	expiry := time.Duration(configClientExpiry) * time.Second
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Drop expired clients (at most once per expiry period) so the store
+	// does not grow forever with clients that never come back
+	if expiry > 0 && now.Sub(s.lastSweep) > expiry {
+		for k, v := range s.visits {
+			if now.Sub(v.last) > expiry {
+				delete(s.visits, k)
+			}
+		}
+		s.lastSweep = now
+	}
+	v, ok := s.visits[key]
+	if !ok || (expiry > 0 && now.Sub(v.last) > expiry) {
+		v = &clientVisit{}
+		s.visits[key] = v
+	}
+	prior := v.count
+	v.count++
+	v.last = now
+	return prior
+}
+
+func (s *clientStore) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.visits = map[string]*clientVisit{}
+}
+
+// Client identity: the configClientHeader value if set and present,
+// otherwise the remote IP address
+func clientKey(r *http.Request) string {
+	if configClientHeader != "" {
+		if v := strings.TrimSpace(r.Header.Get(configClientHeader)); v != "" {
+			return "header:" + v
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
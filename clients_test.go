@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClientExpiry(t *testing.T) {
+	setConfig(t, &configClientExpiry, 60)
+	s := &clientStore{visits: map[string]*clientVisit{}}
+
+	if n := s.visit("users.json", "ip:10.0.0.1"); n != 0 {
+		t.Errorf("first visit = %d, want 0", n)
+	}
+	if n := s.visit("users.json", "ip:10.0.0.1"); n != 1 {
+		t.Errorf("second visit = %d, want 1", n)
+	}
+
+	// Both clients have been idle for longer than the expiry
+	for _, v := range s.visits {
+		v.last = v.last.Add(-2 * time.Minute)
+	}
+	s.visits["orders.json\x00ip:10.0.0.2"] = &clientVisit{count: 3, last: time.Now().Add(-2 * time.Minute)}
+	s.lastSweep = time.Now().Add(-2 * time.Minute)
+
+	if n := s.visit("users.json", "ip:10.0.0.1"); n != 0 {
+		t.Errorf("visit after expiry = %d, want 0", n)
+	}
+	if _, ok := s.visits["orders.json\x00ip:10.0.0.2"]; ok || len(s.visits) != 1 {
+		t.Errorf("expired clients were kept: %d entries", len(s.visits))
+	}
+}
+
+func TestClientsKeptWithoutExpiry(t *testing.T) {
+	setConfig(t, &configClientExpiry, 0)
+	s := &clientStore{visits: map[string]*clientVisit{}}
+	s.visits["orders.json\x00ip:10.0.0.2"] = &clientVisit{count: 3, last: time.Now().Add(-24 * time.Hour)}
+
+	s.visit("users.json", "ip:10.0.0.1")
+	if n := s.visit("orders.json", "ip:10.0.0.2"); n != 3 {
+		t.Errorf("visit = %d, want 3", n)
+	}
+}
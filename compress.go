@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Bodies smaller than this are sent uncompressed
+const compressMinSize = 1024
+
+// Compress a response body with gzip or deflate when the client accepts
+// it, setting Content-Encoding and Content-Length. Returns the body to
+// write (unchanged if it is small, not text or already encoded).
+func compressBody(w http.ResponseWriter, r *http.Request, body []byte) []byte {
+	h := w.Header()
+	if configNoCompression || len(body) < compressMinSize || h.Get("Content-Encoding") != "" || !isTextContentType(h.Get("Content-Type")) {
+		return body
+	}
+	addVary(h, "Accept-Encoding")
+
+	var coding string
+	switch {
+	case acceptsEncoding(r, "gzip"):
+		coding = "gzip"
+	case acceptsEncoding(r, "deflate"):
+		coding = "deflate"
+	default:
+		return body
+	}
+
+	var b bytes.Buffer
+	if coding == "gzip" {
+		zw := gzip.NewWriter(&b)
+		zw.Write(body)
+		zw.Close()
+	} else {
+		zw := zlib.NewWriter(&b)
+		zw.Write(body)
+		zw.Close()
+	}
+	h.Set("Content-Encoding", coding)
+	// The ETag describes the uncompressed body
+	if etag := h.Get("ETag"); strings.HasPrefix(etag, `"`) {
+		h.Set("ETag", "W/"+etag)
+	}
+	h.Set("Content-Length", strconv.Itoa(b.Len()))
+	return b.Bytes()
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+// Per-route concurrency limit
+type Concurrency struct {
+	Max  int    `json:"max"`  // Maximum requests handled at the same time
+	Mode string `json:"mode"` // "queue" (wait for a slot, default) or "reject" (503)
+}
+
+// Requests currently being handled by mockHandler
+var inFlight atomic.Int64
+
+// Counting semaphore
+type semaphore chan struct{}
+
+// Take a slot. With reject, fail immediately when none is free;
+// otherwise wait until one is (or the client goes away).
+func (s semaphore) acquire(r *http.Request, reject bool) bool {
+	if reject {
+		select {
+		case s <- struct{}{}:
+			return true
+		default:
+			return false
+		}
+	}
+	select {
+	case s <- struct{}{}:
+		return true
+	case <-r.Context().Done():
+		return false
+	}
+}
+
+func (s semaphore) release() {
+	<-s
+}
+
+// Global limit (nil when disabled)
+var globalSemaphore semaphore
+
+// Count in-flight requests and apply the global limit
+func limitConcurrency(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		inFlight.Add(1)
+		defer inFlight.Add(-1)
+
+		if globalSemaphore != nil {
+			if !globalSemaphore.acquire(r, configConcurrencyMode == "reject") {
+				respondError(w, r, 503, map[string]string{"error": "Service Unavailable"})
+				return
+			}
+			defer globalSemaphore.release()
+		}
+		next(w, r)
+	}
+}
+
+// Per-route semaphores, recreated when the limit in the file changes
+var (
+	routeSemMu sync.Mutex
+	routeSems  = map[string]semaphore{}
+)
+
+func routeSemaphore(route string, max int) semaphore {
+	routeSemMu.Lock()
+	defer routeSemMu.Unlock()
+	if s, ok := routeSems[route]; ok && cap(s) == max {
+		return s
+	}
+	s := make(semaphore, max)
+	routeSems[route] = s
+	return s
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMaxConcurrentFlagOverridesConfig(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	rc := `{"dir": "` + filepath.ToSlash(t.TempDir()) + `", "maxConcurrent": 5}`
+	if err := os.WriteFile(filepath.Join(home, ".apimockrc"), []byte(rc), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { globalSemaphore = nil })
+
+	initConfig()
+	if configMaxConcurrent != 5 || globalSemaphore == nil {
+		t.Fatalf("maxConcurrent from .apimockrc = %d, want 5", configMaxConcurrent)
+	}
+
+	flag.Set("max-concurrent", "0")
+	t.Cleanup(func() { flag.Set("max-concurrent", "0") })
+	globalSemaphore = nil
+	initConfig()
+	if configMaxConcurrent != 0 || globalSemaphore != nil {
+		t.Errorf("--max-concurrent 0: limit %d, want unlimited", configMaxConcurrent)
+	}
+}
+
+func TestConcurrencyRejectUsesErrorFile(t *testing.T) {
+	newMockDir(t, map[string]string{
+		"busy.json": `{"concurrency": {"max": 1, "mode": "reject"}, "body": {"ok": true}}`,
+	})
+	errorFile := filepath.Join(t.TempDir(), "503.json")
+	if err := os.WriteFile(errorFile, []byte(`{"code": "BUSY", "path": "{path}"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	setConfig(t, &configErrorFiles, map[string]string{"503": errorFile})
+
+	sem := routeSemaphore("busy.json", 1)
+	sem <- struct{}{} // A request in flight
+	defer sem.release()
+	rec := serve(t, newRequest("GET", "/busy", ""))
+	if rec.Code != 503 || rec.Body.String() != `{"code": "BUSY", "path": "/busy"}` {
+		t.Errorf("GET /busy = %d %q, want the 503 error file", rec.Code, rec.Body.String())
+	}
+}
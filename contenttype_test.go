@@ -0,0 +1,30 @@
+package main
+
+import "testing"
+
+func TestDefaultContentType(t *testing.T) {
+	newMockDir(t, map[string]string{
+		"users.json":  `{"body": {"ok": true}}`,
+		"custom.json": `{"headers": {"Content-Type": "application/vnd.api+json; charset=UTF-8"}, "body": {"ok": true}}`,
+	})
+
+	tests := []struct {
+		noCharset bool
+		path      string
+		want      string
+	}{
+		{false, "/users", "application/json; charset=utf-8"},
+		{true, "/users", "application/json"},
+		{false, "/custom", "application/vnd.api+json; charset=UTF-8"},
+		{true, "/custom", "application/vnd.api+json; charset=UTF-8"},
+		{false, "/missing", "application/json; charset=utf-8"},
+		{true, "/missing", "application/json"},
+	}
+	for _, tt := range tests {
+		setConfig(t, &configNoCharset, tt.noCharset)
+		rec := serve(t, newRequest("GET", tt.path, ""))
+		if got := rec.Header().Get("Content-Type"); got != tt.want {
+			t.Errorf("noCharset=%v GET %s: Content-Type %q, want %q", tt.noCharset, tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// A Set-Cookie directive of a mock. The value can use request tokens.
+type Cookie struct {
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Path     string `json:"path"`
+	Domain   string `json:"domain"`
+	MaxAge   int    `json:"maxAge"` // Seconds; negative deletes the cookie (Max-Age=0)
+	HttpOnly bool   `json:"httpOnly"`
+	Secure   bool   `json:"secure"`
+	SameSite string `json:"sameSite"` // "lax", "strict" or "none"
+}
+
+var cookieSameSite = map[string]http.SameSite{
+	"":       http.SameSiteDefaultMode,
+	"lax":    http.SameSiteLaxMode,
+	"strict": http.SameSiteStrictMode,
+	"none":   http.SameSiteNoneMode,
+}
+
+func (c *Cookie) UnmarshalJSON(data []byte) error {
+	type plain Cookie
+	if err := json.Unmarshal(data, (*plain)(c)); err != nil {
+		return fmt.Errorf("cookies must be objects with a name and a value")
+	}
+	if c.Name == "" {
+		return fmt.Errorf("cookie needs a name")
+	}
+	if _, ok := cookieSameSite[strings.ToLower(c.SameSite)]; !ok {
+		return fmt.Errorf("cookie %s: sameSite must be lax, strict or none", c.Name)
+	}
+	return nil
+}
+
+// The cookie to set for a request
+func (c Cookie) httpCookie(td *templateData) *http.Cookie {
+	return &http.Cookie{
+		Name:     c.Name,
+		Value:    td.expand(c.Value),
+		Path:     c.Path,
+		Domain:   c.Domain,
+		MaxAge:   c.MaxAge,
+		HttpOnly: c.HttpOnly,
+		Secure:   c.Secure,
+		SameSite: cookieSameSite[strings.ToLower(c.SameSite)],
+	}
+}
+
+// Whether the request has what want declares for each cookie: true
+// (sent), false (not sent) or its value
+func cookiesMatch(want map[string]interface{}, r *http.Request) bool {
+	for name, v := range want {
+		c, err := r.Cookie(name)
+		switch v := v.(type) {
+		case bool:
+			if (err == nil) != v {
+				return false
+			}
+		default:
+			if err != nil || c.Value != fmt.Sprint(v) {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestSetCookieAttributes(t *testing.T) {
+	newMockDir(t, map[string]string{
+		"login/_.json": `{"cookies": [
+			{"name": "session", "value": "token-{path.0}", "path": "/", "domain": "example.com", "maxAge": 3600, "httpOnly": true, "secure": true, "sameSite": "Strict"},
+			{"name": "theme", "value": "dark", "sameSite": "none", "secure": true},
+			{"name": "old", "value": "", "maxAge": -1}
+		], "body": {}}`,
+	})
+	rec := serve(t, newRequest("GET", "/login/taro", ""))
+	got := rec.Header().Values("Set-Cookie")
+	want := []string{
+		"session=token-taro; Path=/; Domain=example.com; Max-Age=3600; HttpOnly; Secure; SameSite=Strict",
+		"theme=dark; Secure; SameSite=None",
+		"old=; Max-Age=0",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Set-Cookie =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestCookieValidation(t *testing.T) {
+	for body, want := range map[string]string{
+		`{"cookies": [{"value": "x"}]}`:                         "needs a name",
+		`{"cookies": [{"name": "a", "sameSite": "sometimes"}]}`: "sameSite",
+		`{"cookies": ["a=b"]}`:                                  "objects",
+	} {
+		var mock MockResponse
+		if err := json.Unmarshal([]byte(body), &mock); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: error %v, want %q", body, err, want)
+		}
+	}
+}
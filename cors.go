@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// CORS headers of a mock (or of every mock, from .apimockrc). "cors":
+// false sends none.
+type CORS struct {
+	AllowOrigin      string   `json:"allowOrigin"`      // Default: "*", or the origin allowed by corsOrigins
+	AllowMethods     []string `json:"allowMethods"`     // Default: GET,POST,PUT,DELETE,OPTIONS
+	AllowHeaders     []string `json:"allowHeaders"`     // Default: *
+	AllowCredentials bool     `json:"allowCredentials"` // Access-Control-Allow-Credentials: true
+	disabled         bool
+}
+
+func (c *CORS) UnmarshalJSON(data []byte) error {
+	if string(data) == "false" {
+		c.disabled = true
+		return nil
+	}
+	type plain CORS
+	if err := json.Unmarshal(data, (*plain)(c)); err != nil {
+		return fmt.Errorf("cors must be an object or false")
+	}
+	return nil
+}
+
+// Whether CORS is turned off globally (--no-cors or "cors": false), which
+// also stops answering OPTIONS preflight requests automatically
+func corsDisabled() bool {
+	return configCORS != nil && configCORS.disabled
+}
+
+// Set CORS headers from c (nil: the defaults), replacing any set before.
+// With configCORSOrigins, only allowed origins get them (the origin is
+// echoed back); others get none. With credentials, browsers reject "*",
+// so the request's Origin (and requested headers) are echoed instead.
+func applyCORS(w http.ResponseWriter, r *http.Request, c *CORS) {
+	h := w.Header()
+	// Every Access-Control-* header, so none from defaultHeaders is left
+	// next to the configured ones (Expose-Headers, Max-Age, ...)
+	for k := range h {
+		if strings.HasPrefix(k, "Access-Control-") {
+			delete(h, k)
+		}
+	}
+	if c != nil && c.disabled {
+		return
+	}
+	if c == nil {
+		c = &CORS{}
+	}
+	origin := r.Header.Get("Origin")
+
+	allowOrigin := c.AllowOrigin
+	if allowOrigin == "" {
+		allowOrigin = "*"
+		if len(configCORSOrigins) > 0 {
+			addVary(h, "Origin")
+			if !originAllowed(origin) {
+				return
+			}
+			allowOrigin = origin
+		}
+	}
+	if allowOrigin == "*" && c.AllowCredentials {
+		addVary(h, "Origin")
+		if origin == "" {
+			return
+		}
+		allowOrigin = origin
+	}
+	h.Set("Access-Control-Allow-Origin", allowOrigin)
+
+	methods := "GET,POST,PUT,DELETE,OPTIONS"
+	if len(c.AllowMethods) > 0 {
+		methods = strings.Join(c.AllowMethods, ",")
+	}
+	h.Set("Access-Control-Allow-Methods", methods)
+
+	headers := "*"
+	if len(c.AllowHeaders) > 0 {
+		headers = strings.Join(c.AllowHeaders, ",")
+	}
+	if headers == "*" && c.AllowCredentials {
+		headers = r.Header.Get("Access-Control-Request-Headers")
+	}
+	if headers != "" {
+		h.Set("Access-Control-Allow-Headers", headers)
+	}
+	if c.AllowCredentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// Add a value to the Vary header unless it is already there
+func addVary(h http.Header, value string) {
+	for _, v := range h.Values("Vary") {
+		for _, part := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), value) {
+				return
+			}
+		}
+	}
+	h.Add("Vary", value)
+}
+
+// CORS settings of the route a preflight request is for, read without
+// serving it (the global settings if it has none or does not exist)
+func preflightCORS(r *http.Request, baseDir, requestPath string) *CORS {
+	if mock, _, _, ok := matchInlineRoute(requestPath); ok {
+		if mock.CORS != nil {
+			return mock.CORS
+		}
+		return configCORS
+	}
+	method := r.Header.Get("Access-Control-Request-Method")
+	if method == "" {
+		method = r.Method
+	}
+	filePath, _ := findBestMockFile(baseDir, requestPath, method, r.URL.Query())
+	if filePath == "" || isGzipFixture(filePath) {
+		return configCORS
+	}
+	data, err := readMockFile(filePath)
+	if err == nil {
+		data, err = withDefaults(filePath, data)
+	}
+	if err != nil {
+		return configCORS
+	}
+	var mock struct {
+		CORS *CORS `json:"cors"`
+	}
+	if json.Unmarshal(data, &mock) != nil || mock.CORS == nil {
+		return configCORS
+	}
+	return mock.CORS
+}
+
+// Match an Origin against the allowed patterns. "*" matches any part of
+// a host name; patterns without a scheme are matched against the host
+// name only (e.g. "*.example.com"). The "null" origin is only allowed
+// when listed literally.
+func originAllowed(origin string) bool {
+	if origin == "" {
+		return false
+	}
+	for _, pattern := range configCORSOrigins {
+		if origin == "null" {
+			if pattern == "null" {
+				return true
+			}
+			continue
+		}
+		if pattern == "*" {
+			return true
+		}
+
+		target := origin
+		if !strings.Contains(pattern, "://") {
+			u, err := url.Parse(origin)
+			if err != nil {
+				continue
+			}
+			target = u.Hostname()
+		}
+		re, err := compileCached("^" + strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, `[^/:]*`) + "$")
+		if err == nil && re.MatchString(target) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"strings"
+	"testing"
+)
+
+func TestSplitList(t *testing.T) {
+	got := splitList(" https://app.example.com, *.example.org ,,")
+	if strings.Join(got, "|") != "https://app.example.com|*.example.org" {
+		t.Errorf("splitList = %q", got)
+	}
+	if got := splitList(" , "); len(got) != 0 {
+		t.Errorf("splitList of blanks = %q, want none", got)
+	}
+}
+
+func TestCORSOriginAllowlist(t *testing.T) {
+	newMockDir(t, map[string]string{"users.json": `{"body": []}`})
+	setConfig(t, &configCORSOrigins, splitList("https://app.example.com, *.example.org"))
+
+	tests := []struct {
+		origin, want string
+	}{
+		{"https://app.example.com", "https://app.example.com"},
+		{"https://api.example.org", "https://api.example.org"},
+		{"http://api.example.org:8080", "http://api.example.org:8080"},
+		{"https://evil.example.net", ""},
+		{"https://example.org", ""},
+		{"null", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		rec := serve(t, newRequest("GET", "/users", "", "Origin", tt.origin))
+		if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.want {
+			t.Errorf("Origin %q: Access-Control-Allow-Origin %q, want %q", tt.origin, got, tt.want)
+		}
+		if got := rec.Header().Get("Vary"); !strings.Contains(got, "Origin") {
+			t.Errorf("Origin %q: Vary %q, want Origin", tt.origin, got)
+		}
+	}
+}
+
+func TestDefaultHeadersDoNotMixWithCORS(t *testing.T) {
+	newMockDir(t, map[string]string{
+		"users.json": `{"body": []}`,
+		"page.json":  `{"headers": {"Content-Type": "text/html"}, "rawBody": "<p>hi</p>"}`,
+		"raw.json":   `{"rawBody": "id,name"}`,
+	})
+	setConfig(t, &configDefaultHeaders, map[string]string{
+		"Access-Control-Expose-Headers": "X-Secret",
+		"Access-Control-Max-Age":        "86400",
+		"Content-Type":                  "text/csv",
+	})
+	setConfig(t, &configCORS, &CORS{disabled: true})
+
+	rec := serve(t, newRequest("GET", "/users", "", "Origin", "https://app.example.com"))
+	for k := range rec.Header() {
+		if strings.HasPrefix(k, "Access-Control-") {
+			t.Errorf("CORS turned off, but %s was sent", k)
+		}
+	}
+
+	tests := []struct{ target, want string }{
+		{"/raw", "text/csv"},
+		{"/page", "text/html"},
+		{"/missing", "application/json; charset=utf-8"},
+	}
+	for _, tt := range tests {
+		rec := serve(t, newRequest("GET", tt.target, ""))
+		if got := rec.Header().Get("Content-Type"); got != tt.want {
+			t.Errorf("GET %s: Content-Type %q, want %q", tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestNoCORS(t *testing.T) {
+	newMockDir(t, map[string]string{
+		"users.json":         `{"body": []}`,
+		"users.OPTIONS.json": `{"headers": {"Allow": "GET, OPTIONS"}, "body": {"options": true}}`,
+	})
+	setConfig(t, &configAutoMethods, true)
+	setConfig(t, &configCORS, &CORS{disabled: true})
+
+	for _, method := range []string{"GET", "OPTIONS"} {
+		rec := serve(t, newRequest(method, "/users", "", "Origin", "https://app.example.com", "Access-Control-Request-Method", "GET"))
+		for k := range rec.Header() {
+			if strings.HasPrefix(k, "Access-Control-") {
+				t.Errorf("%s with --no-cors: %s was sent", method, k)
+			}
+		}
+	}
+
+	rec := serve(t, newRequest("OPTIONS", "/users", "", "Origin", "https://app.example.com"))
+	if rec.Code != 200 || rec.Header().Get("Allow") != "GET, OPTIONS" || !strings.Contains(rec.Body.String(), `"options": true`) {
+		t.Errorf("OPTIONS /users = %d %q, want users.OPTIONS.json", rec.Code, rec.Body.String())
+	}
+}
+
+func TestGzipFixtureKeepsVaryOrigin(t *testing.T) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte(`{"yearly": true}`))
+	zw.Close()
+	newMockDir(t, map[string]string{"reports/yearly.json.gz": buf.String()})
+	setConfig(t, &configCORSOrigins, splitList("https://app.example.com"))
+
+	for _, encoding := range []string{"gzip", ""} {
+		rec := serve(t, newRequest("GET", "/reports/yearly", "", "Origin", "https://app.example.com", "Accept-Encoding", encoding))
+		vary := strings.Join(rec.Header().Values("Vary"), ", ")
+		if !strings.Contains(vary, "Origin") || !strings.Contains(vary, "Accept-Encoding") {
+			t.Errorf("Accept-Encoding %q: Vary %q, want Origin and Accept-Encoding", encoding, vary)
+		}
+	}
+}
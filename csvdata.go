@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Response computed from a CSV file
+type CSVSource struct {
+	File   string `json:"file"`   // CSV file relative to the mock directory (first row is the header)
+	Column string `json:"column"` // Column to look up
+	Key    string `json:"key"`    // Where the lookup value comes from: "path.N" or "query.NAME"
+}
+
+type csvTable struct {
+	modTime time.Time
+	header  []string
+	rows    [][]string
+}
+
+// Parsed CSV files (re-read when the file changes)
+var (
+	csvMu    sync.Mutex
+	csvCache = map[string]*csvTable{}
+)
+
+func loadCSV(path string) (*csvTable, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	csvMu.Lock()
+	defer csvMu.Unlock()
+	if t, ok := csvCache[path]; ok && t.modTime.Equal(info.ModTime()) {
+		return t, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%s has no header row", path)
+	}
+	t := &csvTable{modTime: info.ModTime(), header: records[0], rows: records[1:]}
+	csvCache[path] = t
+	return t, nil
+}
+
+// Body for a CSV source: the row whose column equals the lookup value
+// (as a JSON object), or all rows if no column is set. found is false
+// if no row matches.
+func csvBody(src *CSVSource, r *http.Request, pathParams []string) (body json.RawMessage, found bool, err error) {
+	t, err := loadCSV(filepath.Join(configDir, src.File))
+	if err != nil {
+		return nil, false, err
+	}
+
+	toObject := func(row []string) map[string]string {
+		obj := map[string]string{}
+		for i, name := range t.header {
+			if i < len(row) {
+				obj[name] = row[i]
+			}
+		}
+		return obj
+	}
+
+	if src.Column == "" {
+		all := []map[string]string{}
+		for _, row := range t.rows {
+			all = append(all, toObject(row))
+		}
+		body, err = json.Marshal(all)
+		return body, true, err
+	}
+
+	col := -1
+	for i, name := range t.header {
+		if name == src.Column {
+			col = i
+		}
+	}
+	if col < 0 {
+		return nil, false, fmt.Errorf("column '%s' not found in %s", src.Column, src.File)
+	}
+
+	value := lookupValue(src.Key, r, pathParams)
+	for _, row := range t.rows {
+		if col < len(row) && row[col] == value {
+			body, err = json.Marshal(toObject(row))
+			return body, true, err
+		}
+	}
+	return nil, false, nil
+}
+
+// Value of a "path.N" or "query.NAME" reference
+func lookupValue(ref string, r *http.Request, pathParams []string) string {
+	if name, ok := strings.CutPrefix(ref, "query."); ok {
+		return r.URL.Query().Get(name)
+	}
+	if idx, ok := strings.CutPrefix(ref, "path."); ok {
+		i, err := strconv.Atoi(idx)
+		if err == nil && i < 0 {
+			i += len(pathParams) // path.-1 is the last one
+		}
+		if err == nil && i >= 0 && i < len(pathParams) {
+			return pathParams[i]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Name of the files holding defaults for the mocks of a directory and
+// its subdirectories (_defaults.json, _defaults.yaml or _defaults.yml)
+const defaultsName = "_defaults"
+
+func isDefaultsFile(name string) bool {
+	base := filepath.Base(name)
+	return base != defaultsName && trimMockExt(base) == defaultsName && !isGzipFixture(base)
+}
+
+// Whether a JSON object has any mock field (otherwise it is a simple
+// mode body)
+func hasMockField(obj map[string]json.RawMessage) bool {
+	for k := range obj {
+		if mockFields[k] {
+			return true
+		}
+	}
+	return false
+}
+
+// Merge the _defaults files from configDir down to the directory of a
+// mock file into it. Closer directories win over outer ones and the
+// file's own fields win over all defaults; headers are merged one by one.
+// Simple mode files and arrays of entries are returned unchanged. Values
+// keep their bytes (and objects their key order).
+func withDefaults(filePath string, data []byte) ([]byte, error) {
+	var obj map[string]json.RawMessage
+	if json.Unmarshal(data, &obj) != nil || !hasMockField(obj) {
+		return data, nil
+	}
+	file, _ := jsonObjectMembers(data)
+	rel, err := filepath.Rel(configDir, filepath.Dir(filePath))
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return data, nil
+	}
+
+	dirs := []string{configDir}
+	if rel != "." {
+		dir := configDir
+		for _, seg := range strings.Split(rel, string(filepath.Separator)) {
+			dir = filepath.Join(dir, seg)
+			dirs = append(dirs, dir)
+		}
+	}
+	var merged []jsonMember
+	found := false
+	for _, dir := range dirs {
+		defaults, err := readDefaults(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range defaults {
+			merged = mergeMockField(merged, m.Key, m.Value)
+			found = true
+		}
+	}
+	if !found {
+		return data, nil
+	}
+	for _, m := range file {
+		merged = mergeMockField(merged, m.Key, m.Value)
+	}
+	return encodeJSONObject(merged), nil
+}
+
+// The defaults of a directory (nil if it has no _defaults file)
+func readDefaults(dir string) ([]jsonMember, error) {
+	for _, ext := range []string{".json", ".yaml", ".yml"} {
+		path := filepath.Join(dir, defaultsName+ext)
+		data, err := readMockFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", routeKey(path), err)
+		}
+		defaults, ok := jsonObjectMembers(data)
+		if !ok || !json.Valid(data) {
+			return nil, fmt.Errorf("%s: must be an object of mock fields", routeKey(path))
+		}
+		return defaults, nil
+	}
+	return nil, nil
+}
+
+func mergeMockField(mock []jsonMember, key string, value json.RawMessage) []jsonMember {
+	if key == "headers" {
+		base, okBase := jsonObjectMembers(jsonMemberValue(mock, key))
+		headers, okHeaders := jsonObjectMembers(value)
+		if okBase && okHeaders {
+			for _, h := range headers {
+				kept := base[:0]
+				for _, m := range base {
+					if !strings.EqualFold(m.Key, h.Key) {
+						kept = append(kept, m)
+					}
+				}
+				base = append(kept, h)
+			}
+			value = encodeJSONObject(base)
+		}
+	}
+	return setJSONMember(mock, key, value)
+}
@@ -0,0 +1,21 @@
+package main
+
+import "testing"
+
+func TestDefaultsKeepBodyAsWritten(t *testing.T) {
+	newMockDir(t, map[string]string{
+		"_defaults.json":   `{"headers": {"X-Api": "v1", "X-Env": "test"}, "status": 202}`,
+		"users/index.json": `{"headers": {"x-env": "dev"}, "body": {"z": 1, "a": "<b>bold</b> & more", "n": 1.50}}`,
+	})
+
+	rec := serve(t, newRequest("GET", "/users", ""))
+	if rec.Code != 202 {
+		t.Errorf("status %d, want 202 from _defaults.json", rec.Code)
+	}
+	if want := `{"z": 1, "a": "<b>bold</b> & more", "n": 1.50}`; rec.Body.String() != want {
+		t.Errorf("body %s, want %s", rec.Body.String(), want)
+	}
+	if rec.Header().Get("X-Api") != "v1" || rec.Header().Get("X-Env") != "dev" {
+		t.Errorf("headers %v, want X-Api from the defaults and X-Env from the file", rec.Header())
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Artificial response delay in milliseconds: either fixed ("delay": 200)
+// or a range to simulate jitter ("delay": {"min": 100, "max": 500})
+type Delay struct {
+	Min int `json:"min"`
+	Max int `json:"max"`
+}
+
+func (d *Delay) UnmarshalJSON(data []byte) error {
+	var ms int
+	if err := json.Unmarshal(data, &ms); err == nil {
+		d.Min, d.Max = ms, ms
+		return nil
+	}
+	var r struct {
+		Min *int `json:"min"`
+		Max *int `json:"max"`
+	}
+	if err := json.Unmarshal(data, &r); err != nil {
+		return fmt.Errorf("delay must be milliseconds or {\"min\": ..., \"max\": ...}")
+	}
+	if r.Min == nil || r.Max == nil {
+		return fmt.Errorf("delay range needs both min and max")
+	}
+	if *r.Min < 0 || *r.Max < *r.Min {
+		return fmt.Errorf("invalid delay range %d-%d", *r.Min, *r.Max)
+	}
+	d.Min, d.Max = *r.Min, *r.Max
+	return nil
+}
+
+// Delay for one request, uniformly random within the range
+func (d Delay) duration() time.Duration {
+	return time.Duration(randomInt(d.Min, d.Max)) * time.Millisecond
+}
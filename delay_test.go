@@ -0,0 +1,25 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNoDelay(t *testing.T) {
+	newMockDir(t, map[string]string{
+		"slow.json":   `{"delay": 2000, "body": {"ok": true}}`,
+		"jitter.json": `{"delay": {"min": 2000, "max": 3000}, "variants": [{"delay": 2000, "body": {}}]}`,
+	})
+	setConfig(t, noDelay, true)
+
+	for _, path := range []string{"/slow", "/jitter"} {
+		start := time.Now()
+		rec := serve(t, newRequest("GET", path, ""))
+		if rec.Code != 200 {
+			t.Errorf("GET %s: status %d", path, rec.Code)
+		}
+		if d := time.Since(start); d > time.Second {
+			t.Errorf("GET %s took %v with --no-delay", path, d)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Standard deprecation headers (global in .apimockrc, or per mock)
+type Deprecation struct {
+	Date    string `json:"date"`    // Deprecation date (default: "{now}")
+	Sunset  string `json:"sunset"`  // Sunset date (optional)
+	Link    string `json:"link"`    // Documentation URL, sent as Link rel="deprecation"
+	Warning string `json:"warning"` // Warning text, sent with warn-code 299
+}
+
+// Headers for a deprecation. Dates may use {now...} tokens; they are sent
+// as Deprecation: @<unix seconds> (RFC 9745) and Sunset: <HTTP-date> (RFC 8594).
+func (d *Deprecation) headers(td *templateData) map[string]string {
+	h := map[string]string{}
+
+	date := d.Date
+	if date == "" {
+		date = "{now}"
+	}
+	if t, ok := parseDate(td.expand(date)); ok {
+		h["Deprecation"] = fmt.Sprintf("@%d", t.Unix())
+	} else {
+		h["Deprecation"] = "?1"
+	}
+
+	if d.Sunset != "" {
+		if t, ok := parseDate(td.expand(d.Sunset)); ok {
+			h["Sunset"] = t.UTC().Format(http.TimeFormat)
+		}
+	}
+	if d.Link != "" {
+		h["Link"] = fmt.Sprintf(`<%s>; rel="deprecation"; type="text/html"`, td.expand(d.Link))
+	}
+	if d.Warning != "" {
+		text := strings.ReplaceAll(td.expand(d.Warning), `"`, `\"`)
+		h["Warning"] = fmt.Sprintf(`299 - "%s"`, text)
+	}
+	return h
+}
+
+// Parse an HTTP-date, RFC 3339 timestamp or YYYY-MM-DD date
+func parseDate(s string) (time.Time, bool) {
+	if t, err := http.ParseTime(s); err == nil {
+		return t, true
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, true
+	}
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, true
+	}
+	return time.Time{}, false
+}
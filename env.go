@@ -0,0 +1,34 @@
+package main
+
+import (
+	"log"
+	"os"
+	"regexp"
+	"sync"
+)
+
+// ${NAME} references to environment variables. The bare $NAME form is not
+// supported so that values like "$5" are never changed.
+var envVarRe = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+var warnedEnvVars sync.Map
+
+// Replace ${NAME} with the value of the environment variable. Undefined
+// variables are left as they are and logged once.
+func expandEnvVars(s string) string {
+	return envVarRe.ReplaceAllStringFunc(s, func(ref string) string {
+		name := envVarRe.FindStringSubmatch(ref)[1]
+		if v, ok := os.LookupEnv(name); ok {
+			return v
+		}
+		if _, warned := warnedEnvVars.LoadOrStore(name, true); !warned {
+			log.Printf("[WARNING] Environment variable %s is not set; ${%s} is left as is", name, name)
+		}
+		return ref
+	})
+}
+
+// Expand environment variables in the string values of a JSON document
+func expandEnvJSON(data []byte) []byte {
+	return []byte(expandJSONStrings(string(data), expandEnvVars))
+}
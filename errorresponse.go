@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Configurable response for errors apimock itself generates
+type ErrorResponse struct {
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers"`
+	Body    json.RawMessage   `json:"body"`
+}
+
+// Write res, falling back to status and the default JSON body for unset
+// fields. tokens are replaced in the custom body (e.g. {missing}).
+func writeErrorResponse(w http.ResponseWriter, res *ErrorResponse, status int, def interface{}, tokens ...string) {
+	if res == nil {
+		res = &ErrorResponse{}
+	}
+	if res.Status != 0 {
+		status = res.Status
+	}
+	for k, v := range res.Headers {
+		w.Header().Set(k, v)
+	}
+	if len(res.Body) == 0 {
+		respondJSON(w, status, def)
+		return
+	}
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", jsonContentType())
+	}
+	w.WriteHeader(status)
+	w.Write([]byte(strings.NewReplacer(tokens...).Replace(string(res.Body))))
+}
+
+// Statuses whose responses can come from errorFiles
+var errorFileStatuses = map[string]bool{"404": true, "405": true, "406": true, "500": true, "503": true}
+
+// Write a 404, 405, 406, 500 or 503 response: the errorFiles file for the status if
+// configured, def as JSON otherwise. The file is a mock file with status,
+// headers and body, or just the body. {method}, {path} and the string
+// values of def (e.g. {error} and {allow}) are available as tokens in it,
+// as are request tokens such as {header.X-Request-Id}.
+func respondError(w http.ResponseWriter, r *http.Request, status int, def interface{}) {
+	file := configErrorFiles[strconv.Itoa(status)]
+	if file == "" {
+		respondJSON(w, status, def)
+		return
+	}
+	res, err := readErrorFile(file)
+	if err != nil {
+		log.Printf("[WARNING] Error response file %s: %v", file, err)
+		respondJSON(w, status, def)
+		return
+	}
+
+	tokens := []string{"{method}", r.Method, "{path}", r.URL.Path}
+	data, _ := json.Marshal(def)
+	var fields map[string]interface{}
+	json.Unmarshal(data, &fields)
+	for k, v := range fields {
+		if s, ok := v.(string); ok {
+			tokens = append(tokens, "{"+k+"}", s)
+		}
+	}
+	replacer := strings.NewReplacer(tokens...)
+	td := newTemplateData(r, nil)
+	expand := func(s string) string { return td.replaceRequestTokens(replacer.Replace(td.expandGenerated(s))) }
+
+	for k, v := range res.Headers {
+		res.Headers[k] = expand(v)
+	}
+	res.Body = json.RawMessage(expandJSONStrings(string(res.Body), expand))
+	writeErrorResponse(w, res, status, def)
+}
+
+// An error response file: a mock file with status, headers and body, or
+// a file that is only the body
+func readErrorFile(file string) (*ErrorResponse, error) {
+	data, err := readMockFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(data, &fields) == nil && (fields["status"] != nil || fields["headers"] != nil || fields["body"] != nil) {
+		var res ErrorResponse
+		if err := json.Unmarshal(data, &res); err != nil {
+			return nil, err
+		}
+		return &res, nil
+	}
+	if !json.Valid(data) {
+		return nil, fmt.Errorf("not valid JSON")
+	}
+	return &ErrorResponse{Body: data}, nil
+}
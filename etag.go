@@ -0,0 +1,59 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Set the ETag of a response (unless the mock sets its own or computeETag
+// is false) and answer a conditional GET or HEAD. Returns true if 304 Not Modified was
+// written: If-None-Match matches the ETag or, without If-None-Match, the
+// mock's Last-Modified is not after If-Modified-Since.
+func writeNotModified(w http.ResponseWriter, r *http.Request, status int, body string, computeETag bool) bool {
+	h := w.Header()
+	if h.Get("ETag") == "" && !configNoETag && computeETag {
+		sum := sha256.Sum256([]byte(body))
+		h.Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	}
+	if status != 200 || (r.Method != "GET" && r.Method != "HEAD") {
+		return false
+	}
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		if !etagMatches(inm, h.Get("ETag")) {
+			return false
+		}
+	} else {
+		since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+		if err != nil {
+			return false
+		}
+		modified, err := http.ParseTime(h.Get("Last-Modified"))
+		if err != nil || modified.Truncate(time.Second).After(since) {
+			return false
+		}
+	}
+
+	h.Del("Content-Type")
+	h.Del("Content-Length")
+	w.WriteHeader(304)
+	return true
+}
+
+// Whether an If-None-Match list has etag ("*" matches any), comparing
+// weakly as RFC 9110 requires
+func etagMatches(list, etag string) bool {
+	if etag == "" {
+		return false
+	}
+	for _, tag := range strings.Split(list, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
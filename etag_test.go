@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestConditionalRequests(t *testing.T) {
+	newMockDir(t, map[string]string{
+		"users.json":   `{"body": [{"id": 1}]}`,
+		"report.json":  `{"headers": {"Last-Modified": "Wed, 01 Jan 2025 00:00:00 GMT"}, "body": {}}`,
+		"created.json": `{"status": 201, "body": {"id": 2}}`,
+	})
+	rec := serve(t, newRequest("GET", "/users", ""))
+	etag := rec.Header().Get("ETag")
+	if rec.Code != 200 || etag == "" {
+		t.Fatalf("GET /users = %d with ETag %q, want 200 with an ETag", rec.Code, etag)
+	}
+
+	tests := []struct {
+		method, target string
+		headers        []string
+		want           int
+	}{
+		{"GET", "/users", []string{"If-None-Match", etag}, 304},
+		{"HEAD", "/users", []string{"If-None-Match", etag}, 304},
+		{"GET", "/users", []string{"If-None-Match", `"other", W/` + etag}, 304},
+		{"GET", "/users", []string{"If-None-Match", "*"}, 304},
+		{"GET", "/users", []string{"If-None-Match", `"other"`}, 200},
+		{"POST", "/users", []string{"If-None-Match", etag}, 200},
+		{"GET", "/created", []string{"If-None-Match", "*"}, 201},
+		{"GET", "/report", []string{"If-Modified-Since", "Wed, 01 Jan 2025 00:00:00 GMT"}, 304},
+		{"GET", "/report", []string{"If-Modified-Since", "Tue, 31 Dec 2024 00:00:00 GMT"}, 200},
+	}
+	for _, tt := range tests {
+		rec := serve(t, newRequest(tt.method, tt.target, "", tt.headers...))
+		if rec.Code != tt.want {
+			t.Errorf("%s %s %v = %d, want %d", tt.method, tt.target, tt.headers, rec.Code, tt.want)
+		}
+		if rec.Code == 304 && rec.Body.Len() != 0 {
+			t.Errorf("%s %s: 304 with a body %q", tt.method, tt.target, rec.Body.String())
+		}
+	}
+}
+
+func TestNoETagWithMeta(t *testing.T) {
+	newMockDir(t, map[string]string{"users.json": `{"body": {"id": 1}}`, "list.json": `{"body": [1]}`})
+	setConfig(t, &configMetaKey, "_meta")
+
+	rec := serve(t, newRequest("GET", "/users", ""))
+	if etag := rec.Header().Get("ETag"); etag != "" {
+		t.Errorf("ETag %q with _meta injected, want none", etag)
+	}
+	if rec := serve(t, newRequest("GET", "/users", "", "If-None-Match", "*")); rec.Code != 200 {
+		t.Errorf("GET /users with _meta and If-None-Match = %d, want 200", rec.Code)
+	}
+	// Arrays get no _meta, so they keep their ETag
+	if rec := serve(t, newRequest("GET", "/list", "")); rec.Header().Get("ETag") == "" {
+		t.Error("no ETag for a body without _meta")
+	}
+}
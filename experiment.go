@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+)
+
+// Sticky A/B bucketing: a request without a (valid) bucket cookie is
+// assigned a bucket at random by weight, and the cookie keeps it there
+type Experiment struct {
+	Cookie  string         `json:"cookie"`  // Cookie holding the bucket (default: "apimock_bucket")
+	Buckets map[string]int `json:"buckets"` // Bucket names and their weights
+	MaxAge  int            `json:"maxAge"`  // Cookie lifetime in seconds (default: 30 days)
+}
+
+func (e *Experiment) cookieName() string {
+	if e.Cookie == "" {
+		return "apimock_bucket"
+	}
+	return e.Cookie
+}
+
+// Bucket of the request, assigning one (and setting the cookie) if needed
+func assignBucket(w http.ResponseWriter, r *http.Request, e *Experiment) string {
+	name := e.cookieName()
+	if c, err := r.Cookie(name); err == nil && e.Buckets[c.Value] > 0 {
+		return c.Value
+	}
+
+	// Sorted so that a --seed gives the same assignments
+	buckets := make([]string, 0, len(e.Buckets))
+	total := 0
+	for b, weight := range e.Buckets {
+		if weight > 0 {
+			buckets = append(buckets, b)
+			total += weight
+		}
+	}
+	if total == 0 {
+		return ""
+	}
+	sort.Strings(buckets)
+
+	bucket := buckets[len(buckets)-1]
+	n := randomInt(0, total-1)
+	for _, b := range buckets {
+		if n < e.Buckets[b] {
+			bucket = b
+			break
+		}
+		n -= e.Buckets[b]
+	}
+
+	maxAge := e.MaxAge
+	if maxAge <= 0 {
+		maxAge = 30 * 24 * 60 * 60
+	}
+	http.SetCookie(w, &http.Cookie{Name: name, Value: bucket, Path: "/", MaxAge: maxAge, SameSite: http.SameSiteLaxMode})
+	return bucket
+}
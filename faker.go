@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// Mocks with "template": true are rendered with text/template first, so
+// {{uuid}}, {{randomInt 1 100}} or {{name}} give new values per request.
+// The {path.0}-style tokens are expanded afterwards, which keeps request
+// values from being run as templates.
+
+var (
+	firstNames = []string{"Taro", "Hanako", "Emma", "Liam", "Olivia", "Noah", "Sofia", "Lucas", "Mia", "Kenji"}
+	lastNames  = []string{"Yamada", "Sato", "Smith", "Johnson", "Garcia", "Muller", "Rossi", "Kim", "Silva", "Tanaka"}
+	cities     = []string{"Tokyo", "Osaka", "London", "Paris", "Berlin", "New York", "Toronto", "Sydney", "Madrid", "Seoul"}
+	companies  = []string{"Acme", "Globex", "Initech", "Umbrella", "Hooli", "Stark Industries", "Wayne Enterprises", "Cyberdyne"}
+	loremWords = []string{"lorem", "ipsum", "dolor", "sit", "amet", "consectetur", "adipiscing", "elit", "sed", "do", "eiusmod", "tempor"}
+)
+
+func pick(list []string) string {
+	return list[randomInt(0, len(list)-1)]
+}
+
+var templateFuncs = template.FuncMap{
+	"uuid":        randomUUID,
+	"now":         func() string { return now().UTC().Format(time.RFC3339) },
+	"timestamp":   func() int64 { return now().Unix() },
+	"date":        func(layout string) string { return now().UTC().Format(layout) },
+	"randomInt":   randomInt,
+	"randomFloat": randomFloat,
+	"randomBool":  func() bool { return randomInt(0, 1) == 1 },
+	"pick":        func(values ...string) string { return pick(values) },
+	"firstName":   func() string { return pick(firstNames) },
+	"lastName":    func() string { return pick(lastNames) },
+	"name":        func() string { return pick(firstNames) + " " + pick(lastNames) },
+	"email": func() string {
+		return fmt.Sprintf("%s.%s%d@example.com", strings.ToLower(pick(firstNames)), strings.ToLower(pick(lastNames)), randomInt(1, 99))
+	},
+	"phone":   func() string { return fmt.Sprintf("+1-555-%03d-%04d", randomInt(0, 999), randomInt(0, 9999)) },
+	"city":    func() string { return pick(cities) },
+	"company": func() string { return pick(companies) },
+	"lorem": func(words int) string {
+		w := make([]string, words)
+		for i := range w {
+			w[i] = pick(loremWords)
+		}
+		return strings.Join(w, " ")
+	},
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+// Parsed templates by text, so a mock is parsed once
+var templateCache sync.Map
+
+func renderTemplate(text string) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+	t, ok := templateCache.Load(text)
+	if !ok {
+		parsed, err := template.New("").Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+		if err != nil {
+			return "", err
+		}
+		t, _ = templateCache.LoadOrStore(text, parsed)
+	}
+	var b strings.Builder
+	if err := t.(*template.Template).Execute(&b, nil); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// A string made of one action piped to json, e.g. "{{randomInt 1 100 | json}}",
+// is replaced with the JSON value itself (here a number, not a string)
+var jsonActionRe = regexp.MustCompile(`^\{\{[^{}]*\|\s*json\s*\}\}$`)
+
+// Render the templates in the string literals of a JSON body
+func renderTemplateJSON(body string) (string, error) {
+	var firstErr error
+	out := mapJSONStrings(body, "{{", func(str string) (string, bool) {
+		rendered, err := renderTemplate(str)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			return "", false
+		}
+		if jsonActionRe.MatchString(str) && json.Valid([]byte(rendered)) {
+			return rendered, true
+		}
+		return quoteJSON(rendered), rendered != str
+	})
+	return out, firstErr
+}
+
+// Parse the templates of a mock with "template": true (nil if they are fine)
+func checkTemplates(mock MockResponse) error {
+	if !mock.Template {
+		return nil
+	}
+	texts := []string{}
+	if mock.RawBody != nil {
+		texts = append(texts, *mock.RawBody)
+	}
+	mapJSONStrings(string(mock.Body), "{{", func(str string) (string, bool) {
+		texts = append(texts, str)
+		return "", false
+	})
+	for _, v := range mock.Headers {
+		texts = append(texts, v)
+	}
+	for _, text := range texts {
+		if _, err := template.New("").Funcs(templateFuncs).Parse(text); err != nil {
+			return fmt.Errorf("invalid template: %v", err)
+		}
+	}
+	return nil
+}
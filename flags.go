@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"reflect"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// Feature flags loaded from configFlagsFile. Values set through the admin
+// API are kept on top of the file's values until reset.
+type flagStore struct {
+	mu        sync.RWMutex
+	modTime   time.Time
+	file      map[string]interface{}
+	overrides map[string]interface{}
+}
+
+var flagState = &flagStore{file: map[string]interface{}{}, overrides: map[string]interface{}{}}
+
+// Reload the flags file if it changed since the last load
+func (s *flagStore) refresh() {
+	if configFlagsFile == "" {
+		return
+	}
+	info, err := os.Stat(configFlagsFile)
+	if err != nil {
+		return
+	}
+
+	s.mu.RLock()
+	fresh := info.ModTime().Equal(s.modTime)
+	s.mu.RUnlock()
+	if fresh {
+		return
+	}
+
+	data, err := os.ReadFile(configFlagsFile)
+	if err != nil {
+		return
+	}
+	var flags map[string]interface{}
+	if err := json.Unmarshal(data, &flags); err != nil {
+		log.Printf("[WARNING] Failed to parse flags file '%s': %v", configFlagsFile, err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.file = flags
+	s.modTime = info.ModTime()
+}
+
+func (s *flagStore) get(name string) (interface{}, bool) {
+	s.refresh()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if v, ok := s.overrides[name]; ok {
+		return v, true
+	}
+	v, ok := s.file[name]
+	return v, ok
+}
+
+// Current flags (file values merged with overrides)
+func (s *flagStore) all() map[string]interface{} {
+	s.refresh()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	flags := map[string]interface{}{}
+	for k, v := range s.file {
+		flags[k] = v
+	}
+	for k, v := range s.overrides {
+		flags[k] = v
+	}
+	return flags
+}
+
+func (s *flagStore) set(flags map[string]interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k, v := range flags {
+		s.overrides[k] = v
+	}
+}
+
+// Drop the overrides and go back to the file's values
+func (s *flagStore) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.overrides = map[string]interface{}{}
+}
+
+var flagTokenRe = regexp.MustCompile(`\{flag\.([^{}]+)\}`)
+
+// Replace {flag.NAME} with the flag's value. Strings are inserted as is,
+// other values as JSON. Unknown flags are left untouched.
+func replaceFlags(s string) string {
+	return flagTokenRe.ReplaceAllStringFunc(s, func(match string) string {
+		v, ok := flagState.get(flagTokenRe.FindStringSubmatch(match)[1])
+		if !ok {
+			return match
+		}
+		if str, ok := v.(string); ok {
+			return str
+		}
+		data, _ := json.Marshal(v)
+		return string(data)
+	})
+}
+
+// Whether every flag has the given value
+func flagsMatch(want map[string]interface{}) bool {
+	for name, value := range want {
+		v, ok := flagState.get(name)
+		if !ok || !reflect.DeepEqual(v, value) {
+			return false
+		}
+	}
+	return true
+}
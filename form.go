@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// A file part of a multipart/form-data request (the content is not kept)
+type formFile struct {
+	Filename    string
+	Size        int64
+	ContentType string
+}
+
+// Fields and file parts of a form request body
+type requestForm struct {
+	values url.Values
+	files  map[string][]formFile
+}
+
+// Parse a multipart/form-data or application/x-www-form-urlencoded body
+// (empty for other bodies). The body is read up to the request's limit
+// and stays readable.
+func parseRequestForm(r *http.Request) (*requestForm, error) {
+	form := &requestForm{values: url.Values{}, files: map[string][]formFile{}}
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || (mediaType != "multipart/form-data" && mediaType != "application/x-www-form-urlencoded") {
+		return form, nil
+	}
+	data, err := readBody(r)
+	if err != nil {
+		return form, err
+	}
+	if mediaType == "application/x-www-form-urlencoded" {
+		form.values, err = url.ParseQuery(string(data))
+		return form, err
+	}
+
+	mr := multipart.NewReader(bytes.NewReader(data), params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return form, nil
+		}
+		if err != nil {
+			return form, err
+		}
+		name := part.FormName()
+		if part.FileName() != "" {
+			size, _ := io.Copy(io.Discard, part)
+			form.files[name] = append(form.files[name], formFile{part.FileName(), size, part.Header.Get("Content-Type")})
+		} else {
+			value, _ := io.ReadAll(part)
+			form.values.Add(name, string(value))
+		}
+	}
+}
+
+// The form of the request, parsed once per request
+func requestFormOf(r *http.Request) (*requestForm, error) {
+	p := requestParsedBody(r)
+	if !p.formParsed {
+		p.formParsed = true
+		p.form, p.formErr = parseRequestForm(r)
+	}
+	return p.form, p.formErr
+}
+
+// Whether the form has what want declares for each name: true (a field
+// or file part is present), false (absent) or the value of a field
+func formMatches(want map[string]interface{}, r *http.Request) bool {
+	form, err := requestFormOf(r)
+	if err != nil {
+		return false
+	}
+	for name, v := range want {
+		present := form.values.Has(name) || len(form.files[name]) > 0
+		switch v := v.(type) {
+		case bool:
+			if present != v {
+				return false
+			}
+		default:
+			if form.values.Get(name) != fmt.Sprint(v) || !form.values.Has(name) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Value of a {file.NAME.ATTR} token: filename (the default), size or
+// contentType of the first file part named NAME
+func (f *requestForm) fileToken(name string) (string, bool) {
+	attr := "filename"
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		switch name[i+1:] {
+		case "filename", "size", "contentType":
+			name, attr = name[:i], name[i+1:]
+		}
+	}
+	files := f.files[name]
+	if len(files) == 0 {
+		return "", false
+	}
+	switch attr {
+	case "size":
+		return strconv.FormatInt(files[0].Size, 10), true
+	case "contentType":
+		return files[0].ContentType, true
+	}
+	return files[0].Filename, true
+}
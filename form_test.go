@@ -0,0 +1,42 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestMatchFormVariants(t *testing.T) {
+	newMockDir(t, map[string]string{
+		"posts.json": `{
+			"method": ["POST"],
+			"body": {"status": "default"},
+			"variants": [
+				{"matchForm": {"title": "draft"}, "body": {"status": "draft"}},
+				{"matchForm": {"title": true, "publish": "yes"}, "body": {"status": "published"}}
+			]
+		}`,
+	})
+	tests := []struct{ body, want string }{
+		{"title=draft", `{"status": "draft"}`},
+		{"title=hello&publish=yes", `{"status": "published"}`},
+		{"title=hello", `{"status": "default"}`},
+	}
+	for _, tt := range tests {
+		rec := serve(t, newRequest("POST", "/posts", tt.body, "Content-Type", "application/x-www-form-urlencoded"))
+		if got := strings.TrimSpace(rec.Body.String()); got != tt.want {
+			t.Errorf("POST %s = %s, want %s", tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestRequestFormParsedOnce(t *testing.T) {
+	r := withParsedBody(newRequest("POST", "/posts", "title=draft", "Content-Type", "application/x-www-form-urlencoded"))
+	if !formMatches(map[string]interface{}{"title": "draft"}, r) {
+		t.Fatal("formMatches: form not parsed")
+	}
+	r.Body = io.NopCloser(strings.NewReader("title=other"))
+	if !formMatches(map[string]interface{}{"title": "draft"}, r) {
+		t.Error("formMatches parsed the body again")
+	}
+}
@@ -1,3 +1,11 @@
 module github.com/akishin/apimock
 
 go 1.23.3
+
+require (
+	github.com/fsnotify/fsnotify v1.10.1
+	google.golang.org/protobuf v1.36.12
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require golang.org/x/sys v0.13.0 // indirect
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Whether the client accepts a gzip encoded response
+func acceptsGzip(r *http.Request) bool {
+	return acceptsEncoding(r, "gzip")
+}
+
+// Whether the client accepts a content coding
+// (an explicit coding takes precedence over "*")
+func acceptsEncoding(r *http.Request, name string) bool {
+	wildcard := -1.0
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		switch coding = strings.TrimSpace(coding); {
+		case strings.EqualFold(coding, name):
+			return qValue(params) > 0
+		case coding == "*":
+			wildcard = qValue(params)
+		}
+	}
+	return wildcard > 0
+}
+
+// Quality value from Accept-* parameters such as "q=0.5" (default: 1)
+func qValue(params string) float64 {
+	for _, p := range strings.Split(params, ";") {
+		if k, v, ok := strings.Cut(p, "="); ok && strings.TrimSpace(k) == "q" {
+			if q, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+				return q
+			}
+		}
+	}
+	return 1
+}
+
+// Serve a .json.gz fixture: the compressed bytes as is when the client
+// accepts gzip, decompressed otherwise
+func serveGzipFixture(w http.ResponseWriter, r *http.Request, filePath string) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		respondError(w, r, 500, map[string]string{"error": "Server Error"})
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", jsonContentType())
+	addVary(w.Header(), "Accept-Encoding")
+
+	if acceptsGzip(r) {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.WriteHeader(200)
+		io.Copy(w, file)
+		return
+	}
+
+	zr, err := gzip.NewReader(file)
+	if err != nil {
+		log.Printf("[WARNING] Invalid gzip fixture %s: %v", filePath, err)
+		respondError(w, r, 500, map[string]string{"error": "Server Error"})
+		return
+	}
+	defer zr.Close()
+	w.WriteHeader(200)
+	io.Copy(w, zr)
+}
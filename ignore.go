@@ -0,0 +1,94 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Always ignored: dotfiles/dot directories (.git, .DS_Store, ...),
+// node_modules and the shared bodies registry
+var defaultIgnorePatterns = []string{".*", "node_modules/", "/" + bodiesDir + "/"}
+
+// Whether a path (relative to configDir, "/" separated) matches an
+// ignore pattern
+func isIgnored(rel string, isDir bool) bool {
+	if rel == "." || rel == "" {
+		return false
+	}
+	for _, pattern := range append(defaultIgnorePatterns, configIgnore...) {
+		dirOnly := strings.HasSuffix(pattern, "/")
+		if dirOnly && !isDir {
+			continue
+		}
+		re, err := compileCached(ignoreRegexp(strings.TrimSuffix(pattern, "/")))
+		if err == nil && re.MatchString(rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// Translate a gitignore-style pattern into a regexp. Patterns containing
+// "/" are relative to the mock directory; others match at any depth.
+func ignoreRegexp(pattern string) string {
+	anchored := strings.Contains(pattern, "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+
+	var b strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			b.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			if end := strings.IndexByte(pattern[i:], ']'); end > 0 {
+				b.WriteString(pattern[i : i+end+1])
+				i += end
+			} else {
+				b.WriteString(`\[`)
+			}
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	if anchored {
+		return "^" + b.String() + "$"
+	}
+	return "(^|/)" + b.String() + "$"
+}
+
+// Walk the mock files below root, skipping ignored files and directories
+func walkMockFiles(root string, fn func(path string)) error {
+	return walkFiles(root, isMockFile, fn)
+}
+
+// Walk the files below root accepted by match, skipping ignored files and
+// directories
+func walkFiles(root string, match func(name string) bool, fn func(path string)) error {
+	return filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(configDir, path)
+		if isIgnored(filepath.ToSlash(rel), d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.IsDir() && match(path) {
+			fn(path)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// {"$include": "common/user.json"} in a body is replaced with the contents
+// of that file (relative to the mock directory). Other keys next to
+// $include are merged into an included object, overriding its keys.
+const includeKey = "$include"
+
+// Resolve every $include in the body, including those in included files.
+// The body is rewritten as raw JSON, so keys keep their order and a body
+// without an include that resolves is returned as it is.
+func expandIncludes(body json.RawMessage) (json.RawMessage, error) {
+	if !bytes.Contains(body, []byte(`"`+includeKey+`"`)) || !json.Valid(body) {
+		return body, nil
+	}
+	return resolveIncludes(body, nil)
+}
+
+// stack holds the files being included, to detect cycles
+func resolveIncludes(raw json.RawMessage, stack []string) (json.RawMessage, error) {
+	return rewriteJSON(raw, func(v json.RawMessage) (json.RawMessage, bool, error) {
+		members, ok := jsonObjectMembers(v)
+		if !ok {
+			return nil, false, nil
+		}
+		// Only a string $include is an include
+		var name string
+		value := bytes.TrimSpace(jsonMemberValue(members, includeKey))
+		if len(value) == 0 || value[0] != '"' || json.Unmarshal(value, &name) != nil {
+			return nil, false, nil
+		}
+		included, err := loadInclude(name, stack)
+		if err != nil {
+			return nil, false, err
+		}
+		if len(members) == 1 {
+			return included, true, nil
+		}
+		obj, ok := jsonObjectMembers(included)
+		if !ok {
+			return nil, false, fmt.Errorf("include '%s' is not an object, so no keys can be merged into it", name)
+		}
+		for _, m := range members {
+			if m.Key == includeKey {
+				continue
+			}
+			child, err := resolveIncludes(m.Value, stack)
+			if err != nil {
+				return nil, false, err
+			}
+			obj = setJSONMember(obj, m.Key, child)
+		}
+		return encodeJSONObject(obj), true, nil
+	})
+}
+
+// Read an included file (JSON or YAML) with its own includes resolved
+func loadInclude(name string, stack []string) (json.RawMessage, error) {
+	clean := path.Clean("/" + name)[1:]
+	if clean == "" || clean != name {
+		return nil, fmt.Errorf("invalid include '%s'", name)
+	}
+	for _, s := range stack {
+		if s == name {
+			return nil, fmt.Errorf("include cycle: %s -> %s", strings.Join(stack, " -> "), name)
+		}
+	}
+	data, err := readMockFile(filepath.Join(configDir, filepath.FromSlash(name)))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("include '%s' not found", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("include '%s': %v", name, err)
+	}
+	if err := json.Unmarshal(data, new(json.RawMessage)); err != nil {
+		return nil, fmt.Errorf("include '%s' is not valid JSON: %v", name, err)
+	}
+	return resolveIncludes(bytes.TrimSpace(data), append(stack, name))
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestExpandIncludes(t *testing.T) {
+	newMockDir(t, map[string]string{
+		"common/user.json":  `{"z": 1, "name": "Taro", "price": 1.50}`,
+		"common/list.json":  `[{"$include": "common/user.json"}]`,
+		"common/cycle.json": `{"$include": "common/cycle.json"}`,
+		"common/tags.yaml":  "- a\n- b\n",
+	})
+	tests := []struct {
+		body, want string
+	}{
+		// Nothing to include: the body is kept byte for byte
+		{`{"b": 1, "a": 1.50, "s": "é"}`, `{"b": 1, "a": 1.50, "s": "é"}`},
+		{`{"b": 1, "$include": 5, "a": "<&>"}`, `{"b": 1, "$include": 5, "a": "<&>"}`},
+		{`{"$include": null}`, `{"$include": null}`},
+		// Keys keep their order; merged keys override those of the include
+		{`{"b": 2, "user": {"$include": "common/user.json"}, "a": 1.50}`, `{"b":2,"user":{"z": 1, "name": "Taro", "price": 1.50},"a":1.50}`},
+		{`{"$include": "common/user.json", "name": "Jiro", "extra": true}`, `{"z":1,"name":"Jiro","price":1.50,"extra":true}`},
+		{`{"users": {"$include": "common/list.json"}}`, `{"users":[{"z": 1, "name": "Taro", "price": 1.50}]}`},
+		{`{"tags": {"$include": "common/tags.yaml"}}`, `{"tags":["a","b"]}`},
+	}
+	for _, tt := range tests {
+		got, err := expandIncludes(json.RawMessage(tt.body))
+		if err != nil {
+			t.Errorf("expandIncludes(%s): %v", tt.body, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("expandIncludes(%s) = %s, want %s", tt.body, got, tt.want)
+		}
+	}
+
+	for body, want := range map[string]string{
+		`{"$include": "common/cycle.json"}`:            "include cycle",
+		`{"$include": "common/missing.json"}`:          "not found",
+		`{"$include": "../etc/passwd"}`:                "invalid include",
+		`{"$include": "common/list.json", "extra": 1}`: "not an object",
+	} {
+		if _, err := expandIncludes(json.RawMessage(body)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("expandIncludes(%s) error = %v, want %q", body, err, want)
+		}
+	}
+}
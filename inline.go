@@ -0,0 +1,52 @@
+package main
+
+import (
+	"strings"
+)
+
+// Route keys of inline routes are "inline:" + path
+const inlinePrefix = "inline:"
+
+// Mock defined in .apimockrc instead of a file
+type InlineRoute struct {
+	Path string `json:"path"` // e.g. "/ping" or "/users/_" ("_" matches any segment, "_:[0-9]+" one matching the regexp)
+	MockResponse
+}
+
+// First inline route matching the request path, with the segments
+// captured by "_" as path parameters
+func matchInlineRoute(requestPath string) (MockResponse, string, []string, bool) {
+	segments := strings.Split(strings.Trim(requestPath, "/"), "/")
+	for _, route := range configInlineRoutes {
+		key := inlinePrefix + route.Path
+		if isRouteDisabled(key) {
+			continue
+		}
+		if params, ok := matchInlinePath(route.Path, segments); ok {
+			return route.MockResponse, key, params, true
+		}
+	}
+	return MockResponse{}, "", nil, false
+}
+
+func matchInlinePath(pattern string, segments []string) ([]string, bool) {
+	parts := strings.Split(strings.Trim(pattern, "/"), "/")
+	if len(parts) != len(segments) {
+		return nil, false
+	}
+	params := []string{}
+	for i, part := range parts {
+		switch {
+		case part == "_":
+			params = append(params, segments[i])
+		case isRegexpSegment(part):
+			if !matchSegmentRegexp(part, segments[i]) {
+				return nil, false
+			}
+			params = append(params, segments[i])
+		case !segmentEqual(part, segments[i]):
+			return nil, false
+		}
+	}
+	return params, true
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// A JavaScript identifier, optionally dotted (jQuery123_456, app.cb)
+var jsonpCallbackRe = regexp.MustCompile(`^[A-Za-z_$][0-9A-Za-z_$]*(\.[A-Za-z_$][0-9A-Za-z_$]*)*$`)
+
+const maxJSONPCallback = 128
+
+// The ?callback= to wrap the JSON body of a mock with jsonp in ("" for
+// other mocks, bodies that are not JSON and requests without one).
+// Returns false if the callback is invalid (400 has been written).
+func jsonpCallback(w http.ResponseWriter, r *http.Request, mock MockResponse) (string, bool) {
+	callback := r.URL.Query().Get("callback")
+	if !mock.JSONP || callback == "" || !strings.Contains(w.Header().Get("Content-Type"), "json") {
+		return "", true
+	}
+	if len(callback) > maxJSONPCallback || !jsonpCallbackRe.MatchString(callback) {
+		respondJSON(w, 400, map[string]string{"error": "Invalid JSONP callback"})
+		return "", false
+	}
+	return callback, true
+}
+
+// Wrap a JSON body in a JSONP callback and set the JavaScript Content-Type
+func wrapJSONP(w http.ResponseWriter, callback, body string) string {
+	contentType := "application/javascript"
+	if !configNoCharset {
+		contentType += "; charset=utf-8"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	// The leading comment keeps the response from starting with bytes the
+	// client controls
+	return "/**/" + callback + "(" + strings.TrimRight(body, "\n") + ");"
+}
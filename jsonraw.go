@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// Bodies are rewritten as raw JSON rather than through interface{}, so
+// the keys keep the order of the mock file, numbers keep their precision
+// and strings their escapes.
+
+// Member of a JSON object, with its value as raw JSON
+type jsonMember struct {
+	Key   string
+	Value json.RawMessage
+}
+
+// Members of a JSON object in order (false if raw is not an object)
+func jsonObjectMembers(raw json.RawMessage) ([]jsonMember, bool) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || raw[0] != '{' {
+		return nil, false
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	if _, err := dec.Token(); err != nil {
+		return nil, false
+	}
+	var members []jsonMember
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return nil, false
+		}
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, false
+		}
+		members = append(members, jsonMember{key.(string), value})
+	}
+	return members, true
+}
+
+// Elements of a JSON array (false if raw is not an array)
+func jsonArrayElements(raw json.RawMessage) ([]json.RawMessage, bool) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || raw[0] != '[' {
+		return nil, false
+	}
+	var elems []json.RawMessage
+	if json.Unmarshal(raw, &elems) != nil {
+		return nil, false
+	}
+	return elems, true
+}
+
+func encodeJSONObject(members []jsonMember) json.RawMessage {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, m := range members {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(quoteJSON(m.Key))
+		b.WriteByte(':')
+		b.Write(m.Value)
+	}
+	b.WriteByte('}')
+	return b.Bytes()
+}
+
+func encodeJSONArray(elems []json.RawMessage) json.RawMessage {
+	var b bytes.Buffer
+	b.WriteByte('[')
+	for i, e := range elems {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.Write(e)
+	}
+	b.WriteByte(']')
+	return b.Bytes()
+}
+
+// Value of a JSON object member (nil if it is missing)
+func jsonMemberValue(members []jsonMember, key string) json.RawMessage {
+	for _, m := range members {
+		if m.Key == key {
+			return m.Value
+		}
+	}
+	return nil
+}
+
+// Set a member, keeping its position if it exists and appending it otherwise
+func setJSONMember(members []jsonMember, key string, value json.RawMessage) []jsonMember {
+	for i := range members {
+		if members[i].Key == key {
+			members[i].Value = value
+			return members
+		}
+	}
+	return append(members, jsonMember{key, value})
+}
+
+// Rewrite a JSON value top-down: fn may replace a value (returning true),
+// otherwise the elements of objects and arrays are visited in turn. Values
+// that are not replaced keep their bytes.
+func rewriteJSON(raw json.RawMessage, fn func(json.RawMessage) (json.RawMessage, bool, error)) (json.RawMessage, error) {
+	if replaced, ok, err := fn(raw); err != nil || ok {
+		return replaced, err
+	}
+	if members, ok := jsonObjectMembers(raw); ok {
+		changed := false
+		for i, m := range members {
+			v, err := rewriteJSON(m.Value, fn)
+			if err != nil {
+				return nil, err
+			}
+			changed = changed || !bytes.Equal(v, m.Value)
+			members[i].Value = v
+		}
+		if !changed {
+			return raw, nil
+		}
+		return encodeJSONObject(members), nil
+	}
+	if elems, ok := jsonArrayElements(raw); ok {
+		changed := false
+		for i, e := range elems {
+			v, err := rewriteJSON(e, fn)
+			if err != nil {
+				return nil, err
+			}
+			changed = changed || !bytes.Equal(v, e)
+			elems[i] = v
+		}
+		if !changed {
+			return raw, nil
+		}
+		return encodeJSONArray(elems), nil
+	}
+	return raw, nil
+}
+
+// json.Marshal without escaping <, > and &
+func marshalJSON(v interface{}) (json.RawMessage, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
@@ -1,49 +1,337 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
     "flag"
+    "fmt"
     "io"
+    "io/fs"
     "log"
+    "math"
+    "net"
     "net/http"
+    "net/url"
     "os"
     "path/filepath"
-    "regexp"
+    "sort"
     "strconv"
     "strings"
     "time"
 )
 
 var (
-    mockDir     = flag.String("dir", "", "Mock directory (if empty, use config file or default)")
-    port        = flag.String("port", "", "Port number (if empty, use config file or 8080)")
-    showVersion = flag.Bool("version", false, "Show version information")
-    _           = flag.Bool("v", false, "Show version information (short)")
+    mockDir         = flag.String("dir", "", "Mock directory (if empty, use config file or default)")
+    port            = newPortFlag("port", "Port number, or PORT=SUBDIR to serve a subdirectory; repeatable (if empty, use config file or 8080)")
+    listenHost      = flag.String("host", "", "Address to listen on, e.g. 127.0.0.1 (if empty, use config file or all interfaces)")
+    socketPath      = flag.String("socket", "", "Listen on this Unix domain socket instead of TCP ports")
+    showVersion     = flag.Bool("version", false, "Show version information")
+    _               = flag.Bool("v", false, "Show version information (short)")
+    checkMode       = flag.Bool("check", false, "Validate mock files and exit")
+    showRoutes      = flag.Bool("routes", false, "Print the route table (pattern, methods, status, file) and exit")
+    matchRequest    = flag.String("match", "", "Print the mock file that answers a request such as 'GET /users/1' and exit")
+    fromOpenAPI     = flag.String("from-openapi", "", "Write mock files for the paths of an OpenAPI/Swagger spec (YAML or JSON) into the mock directory and exit")
+    strictFields    = flag.Bool("strict-fields", false, "Report unknown fields in mock files")
+    validate        = flag.Bool("validate", true, "Validate mock files at startup and log problems")
+    strict          = flag.Bool("strict", false, "Exit if validating mock files at startup finds problems")
+    seed            = flag.Int64("seed", 0, "Seed for generated random data (if 0, random)")
+    deterministic   = flag.Bool("deterministic", false, "Reproducible output: fixed seed (unless --seed), pinned clock and Date header")
+    hostRouting     = flag.Bool("host-routing", false, "Route requests to hosts/<host>/ subdirectories by Host header")
+    noCharset       = flag.Bool("no-charset", false, "Omit '; charset=utf-8' from the default JSON Content-Type")
+    autoMethods     = flag.Bool("auto-methods", true, "Answer HEAD for GET mocks and OPTIONS preflight automatically")
+    suggest         = flag.Bool("suggest", false, "Include the requested path and similar routes in 404 responses")
+    noDelay         = flag.Bool("no-delay", false, "Ignore all artificial response delays")
+    noCORS          = flag.Bool("no-cors", false, "Send no CORS headers and match OPTIONS requests like other methods")
+    corsOrigins     = flag.String("cors-origins", "", "Comma-separated allowed CORS origins (wildcards allowed; if empty, allow all)")
+    staticDir       = flag.String("static", "", "Directory of static files served for paths without a mock (SPA fallback to index.html)")
+    ignore          = flag.String("ignore", "", "Comma-separated gitignore-style patterns of files to ignore in the mock directory")
+    maxConcurrent   = flag.Int("max-concurrent", 0, "Maximum mock requests handled at the same time (0: unlimited)")
+    concurrencyMode = flag.String("concurrency-mode", "", "What to do beyond --max-concurrent: queue (default) or reject (503)")
+    flagsFile       = flag.String("flags", "", "JSON file of feature flags available as {flag.NAME}")
+    traceContext    = flag.Bool("trace-context", false, "Continue W3C traceparent headers with a child span (tokens {trace.id}, {trace.spanId})")
+    injectMeta      = flag.String("inject-meta", "", "Add this key with processing time and matched route to JSON object bodies (e.g. _meta)")
+    requireHeaders  = flag.String("require-headers", "", "Comma-separated request headers every request must send (400 otherwise)")
+    trailingNewline = flag.String("trailing-newline", "", "Trailing newline of text/JSON bodies: add or strip (if empty, keep as written)")
+    warmupSeconds   = flag.Int("warmup-seconds", 0, "Answer 503 with Retry-After for this many seconds after startup")
+    requestLog      = flag.String("request-log", "", "Append every request and its response to this file (JSON Lines, for 'apimock replay')")
+    browse          = flag.Bool("browse", false, "List available routes for directory paths without index.json")
+    admin           = flag.Bool("admin", false, "Enable the dashboard and the Admin API endpoints that change state")
+    watch           = flag.Bool("watch", true, "Watch the mock directory and .apimockrc files and log changes")
+    upstream        = flag.String("upstream", "", "Forward requests without a matching mock to this base URL (e.g. https://api.example.com)")
+    record          = flag.Bool("record", false, "Save responses forwarded to --upstream as mock files")
+    recordOverwrite = flag.Bool("record-overwrite", false, "Replace existing mock files when recording")
+    tlsCert         = flag.String("tls-cert", "", "TLS certificate file; serve HTTPS (needs --tls-key)")
+    tlsKey          = flag.String("tls-key", "", "TLS private key file (needs --tls-cert)")
+    tlsSelfSigned   = flag.Bool("tls-self-signed", false, "Serve HTTPS with a self-signed certificate generated at startup")
+    maxRequestBody  = flag.Int64("max-request-body", 0, "Reject request bodies larger than this many bytes with 413 (0: off)")
+    caseInsensitive = flag.Bool("case-insensitive-paths", false, "Match request paths to mock files ignoring case (/Users serves users.json)")
+    embeddedWild    = flag.Bool("embedded-wildcards", false, "Treat _ inside a file or directory name as a wildcard (users-_.json serves /users-42)")
+    expandEnv       = flag.Bool("expand-env", false, "Replace ${NAME} in mock files with environment variables")
+    noCompression   = flag.Bool("no-compression", false, "Never gzip or deflate responses, even when the client accepts it")
+    noETag          = flag.Bool("no-etag", false, "Do not add a computed ETag to responses (mocks can still set their own)")
+    logLevel        = flag.String("log-level", "", "Access log level: off (default), info (Common Log Format) or debug (matched file, status, duration)")
 
     version = "v1.1.1"
     buildDate = "2025-12-12"
 
     configDir  string // Directory to use eventually
-    configPort string // Port to use eventually
+    configPort   string       // Port to use eventually (the first one)
+    configPorts  []listenPort // All ports to listen on
+    configHost   string       // Address to listen on ("" for all interfaces)
+    configSocket string       // Unix domain socket to listen on instead of the ports
+
+    configForceStatusHeader string        // Request header that overrides the status of opted-in mocks
+    configHostRouting       bool          // Select a hosts/ subdirectory by Host header
+    configNoCharset         bool          // Bare "application/json" as the default Content-Type
+    configAutoMethods       bool          // Synthesize HEAD (from GET) and OPTIONS preflight
+    configCORSOrigins       []string      // Allowed CORS origin patterns (empty: allow all)
+    configStaticDir         string        // Static files served when no mock matches
+    configIgnore            []string      // Extra ignore patterns for the mock directory
+    configMaxConcurrent     int           // Global concurrency limit (0: unlimited)
+    configConcurrencyMode   string        // "queue" or "reject"
+    configFlagsFile         string        // Feature flags file
+    configDeprecation       *Deprecation  // Deprecation headers for every mock
+    configRequestLog        string        // File every request is logged to
+    configTraceContext      bool          // Handle W3C Trace Context headers
+    configWatchDirs         []string      // Watched subdirectories of configDir (empty: all)
+    configWatchDebounce     int           // Milliseconds to wait for more changes before reporting (0: default)
+    configMetaKey           string        // Key of injected response metadata (empty: off)
+    configTrailingNewline   string        // "add" or "strip" (empty: keep bodies as they are)
+    configDeterministic     bool          // Fixed seed and pinned clock for golden-file tests
+    configWarmupSeconds     int           // Not ready (503) for this many seconds after startup
+    configClientHeader      string        // Request header identifying clients (empty: remote IP)
+    configClientExpiry      int           // Seconds after which an idle client counts as new (0: never)
+    configInlineRoutes      []InlineRoute // Routes defined in .apimockrc (checked before files)
+    configRateLimit         *RateLimit    // Rate limit shared by all mock requests
+    configCORS              *CORS         // CORS headers of mocks without their own
+    configAuth              *Auth         // Credentials required by mocks without their own auth
+    configLogLevel          string        // Access log level of routes without their own logLevel
+    configUpstream          string        // Base URL of the real API for unmatched requests
+    configTLSCert           string        // TLS certificate file (empty: plain HTTP)
+    configTLSKey            string        // TLS private key file
+    configTLSSelfSigned     bool          // Serve HTTPS with a generated certificate
+    configNoCompression     bool          // Never compress responses
+    configNoETag            bool          // Never add a computed ETag
+    configExpandEnv         bool          // Replace ${NAME} in mock files with environment variables
+    configCaseInsensitive   bool          // Match path segments ignoring case
+    configEmbeddedWildcards bool          // Treat _ inside a name as a wildcard
+    configMaxRequestBody    int64         // Bytes; larger request bodies get 413 (0: only checked when read)
+    configWatch             bool          // Watch files and log changes
+    configAdmin             bool          // Enable the dashboard and the mutating Admin API endpoints
+    configValidate          bool          // Validate mock files at startup
+    configLegacyRawFallback bool          // Serve files that fail to parse as they are
+    configStrict            bool          // Exit if the validation finds problems
+
+    configRequireHeaders         []string       // Headers every request must send
+    configRequireHeadersExempt   []string       // Path patterns exempt from configRequireHeaders
+    configRequireHeadersResponse *ErrorResponse // Response when a required header is missing
+    configBodyTooLargeResponse   *ErrorResponse // Response when a request body exceeds the limit
+    configWarmupResponse         *ErrorResponse // Response during the warm-up period
+
+    configDefaultStatus  int               // Status of mocks without their own (default: 200)
+    configDefaultHeaders map[string]string // Headers of every response (mock headers win)
+    configErrorFiles     map[string]string // Files of the 404, 405, 406, 500 and 503 responses, by status
 )
 
 type Config struct {
-    Dir  string      `json:"dir"`
-    Port interface{} `json:"port"`
+    Dir    string      `json:"dir"`
+    Port   interface{} `json:"port"`
+    Host   string      `json:"host"`
+    Socket string      `json:"socket"`
+
+    ForceStatusHeader string        `json:"forceStatusHeader"`
+    HostRouting       bool          `json:"hostRouting"`
+    NoCharset         bool          `json:"noCharset"`
+    AutoMethods       *bool         `json:"autoMethods"` // Default: true
+    CORSOrigins       []string      `json:"corsOrigins"`
+    Static            string        `json:"static"`
+    Ignore            []string      `json:"ignore"`
+    MaxConcurrent     int           `json:"maxConcurrent"`
+    ConcurrencyMode   string        `json:"concurrencyMode"`
+    Flags             string        `json:"flags"`
+    Deprecation       *Deprecation  `json:"deprecation"`
+    RequestLog        string        `json:"requestLog"`
+    TraceContext      bool          `json:"traceContext"`
+    WatchDirs         []string      `json:"watchDirs"`
+    WatchDebounce     int           `json:"watchDebounce"`
+    InjectMeta        string        `json:"injectMeta"`
+    TrailingNewline   string        `json:"trailingNewline"`
+    Deterministic     bool          `json:"deterministic"`
+    WarmupSeconds     int           `json:"warmupSeconds"`
+    ClientHeader      string        `json:"clientHeader"`
+    ClientExpiry      int           `json:"clientExpiry"`
+    InlineRoutes      []InlineRoute `json:"inlineRoutes"`
+    RateLimit         *RateLimit    `json:"rateLimit"`
+    CORS              *CORS         `json:"cors"`
+    Auth              *Auth         `json:"auth"`
+    LogLevel          string        `json:"logLevel"`
+    Upstream          string        `json:"upstream"`
+    TLSCert           string        `json:"tlsCert"`
+    TLSKey            string        `json:"tlsKey"`
+    TLSSelfSigned     bool          `json:"tlsSelfSigned"`
+    NoCompression     bool          `json:"noCompression"`
+    NoETag            bool          `json:"noEtag"`
+    ExpandEnv         bool          `json:"expandEnv"`
+    CaseInsensitive   bool          `json:"caseInsensitivePaths"`
+    EmbeddedWildcards bool          `json:"embeddedWildcards"`
+    MaxRequestBody    int64         `json:"maxRequestBody"`
+    Watch             *bool         `json:"watch"`    // Default: true
+    Admin             bool          `json:"admin"`
+    Validate          *bool         `json:"validate"` // Default: true
+    Strict            bool          `json:"strict"`
+    LegacyRawFallback *bool         `json:"legacyRawFallback"` // Default: true
+
+    RequireHeaders         []string       `json:"requireHeaders"`
+    RequireHeadersExempt   []string       `json:"requireHeadersExempt"`
+    RequireHeadersResponse *ErrorResponse `json:"requireHeadersResponse"`
+    BodyTooLargeResponse   *ErrorResponse `json:"bodyTooLargeResponse"`
+    WarmupResponse         *ErrorResponse `json:"warmupResponse"`
+
+    DefaultStatus  int               `json:"defaultStatus"`
+    DefaultHeaders map[string]string `json:"defaultHeaders"`
+    ErrorFiles     map[string]string `json:"errorFiles"`
 }
 
 type MockResponse struct {
 	Method  []string          `json:"method"`  // e.g. ["GET"], ["POST"], ["GET","POST"]
 	Status  int               `json:"status"`  // Optional (default: 200)
-	Delay   int               `json:"delay"`   // Milliseconds
+	Delay   Delay             `json:"delay"`   // Milliseconds, fixed or {"min", "max"}
 	Headers map[string]string `json:"headers"` // Arbitrary custom headers
 	Body    json.RawMessage   `json:"body"`    // Holds raw JSON
+	RawBody *string           `json:"rawBody"` // Served verbatim (no JSON validation or templates)
+	BodyRef string            `json:"bodyRef"` // Name of a shared body in _bodies/ (replaces body)
+
+	// Render body and headers with text/template ({{uuid}}, {{name}}, ...)
+	Template bool `json:"template"`
+
+	// Wrap the JSON body in the ?callback= of a request (always 200)
+	JSONP bool `json:"jsonp"`
+
+	// Bodies by media type, chosen by the Accept header (replace body)
+	Representations Representations `json:"representations"`
+
+	// File (relative to the mock directory) served as the body instead of body
+	BodyFile string `json:"bodyFile"`
+
+	// Behavior for "Expect: 100-continue" requests:
+	// "send" (send 100 Continue, then the final response) or
+	// "reject" (send the final response without reading the body)
+	Continue string `json:"continue"`
+
+	// Responses served in order as the version index is advanced
+	Versions []MockResponse `json:"versions"`
+
+	// Responses served one per request, in order
+	Sequence     []MockResponse `json:"sequence"`
+	SequenceMode string         `json:"sequenceMode"` // "last" (default: repeat the last one) or "cycle"
+
+	// Allow the force-status request header (X-Force-Status) to override Status
+	ForceStatus bool `json:"forceStatus"`
+
+	// JSON Schema file (relative to the mock directory) used to fill
+	// properties missing from Body with random values
+	SchemaFill string `json:"schemaFill"`
+
+	// Maximum request body size in bytes (replaces the global maxRequestBody)
+	MaxRequestBody int64 `json:"maxRequestBody"`
+
+	// Credentials required in the Authorization header (Basic or Bearer)
+	Auth *Auth `json:"auth"`
+
+	// Limit the number of requests per time window
+	RateLimit *RateLimit `json:"rateLimit"`
+
+	// Cache the rendered headers and body for this many seconds
+	CacheTTL int `json:"cacheTTL"`
+
+	// Compute the body from a row of a CSV file
+	CSV *CSVSource `json:"csv"`
+
+	// Limit the requests handled at the same time
+	Concurrency *Concurrency `json:"concurrency"`
+
+	// Redirect to another (possibly external) URL
+	Redirect *Redirect `json:"redirect"`
+
+	// Deprecation, Sunset, Link and Warning headers (overrides the global setting)
+	Deprecation *Deprecation `json:"deprecation"`
+
+	// Send the body in chunks with a pause between them (streaming JSON, SSE)
+	Stream *Stream `json:"stream"`
+
+	// Send the body as Protocol Buffers binary
+	Protobuf *ProtobufEncoding `json:"protobuf"`
+
+	// Answer 503 until this many seconds after startup
+	WarmupSeconds int `json:"warmupSeconds"`
+
+	// Access log level for this route: "off", "info" or "debug"
+	LogLevel string `json:"logLevel"`
+
+	// CORS headers (replaces the global cors setting)
+	CORS *CORS `json:"cors"`
+
+	// Sticky A/B bucket assignment (matched by the bucket of variants)
+	Experiment *Experiment `json:"experiment"`
+
+	// Cookies to set (Set-Cookie)
+	Cookies []Cookie `json:"cookies"`
+
+	// Alternative responses; the first one whose matchers all match is served
+	Variants []MockResponse `json:"variants"`
+
+	// Matchers (for variants)
+	BodyRegex  string                 `json:"bodyRegex"`  // Regexp searched in the raw request body
+	Flags      map[string]interface{} `json:"flags"`      // Feature flags that must have these values
+	Bucket     string                 `json:"bucket"`     // Experiment bucket of the request
+	AuthScheme string                 `json:"authScheme"` // Authorization scheme, e.g. "Bearer", or "none"/"malformed"
+
+	// true: the client's first request to the route, false: a returning client
+	FirstRequest *bool `json:"firstRequest"`
+
+	// JSON the request body must contain for this response to be served
+	MatchBody interface{} `json:"matchBody"`
+
+	// Form fields and file parts (multipart/form-data or urlencoded) the
+	// request must have: a value, true (present) or false (absent)
+	MatchForm map[string]interface{} `json:"matchForm"`
+
+	// Cookies the request must have: a value, true (sent) or false (not sent)
+	MatchCookies map[string]interface{} `json:"matchCookies"`
+
+	// Query parameters (name: value) the request must have for this file to be served
+	Query map[string]string `json:"query"`
+
+	// Condition expression, e.g. "query.role == 'admin' && header['X-Env'] == 'prod'"
+	When string `json:"when"`
+
+	// Loaded from an array of entries: 404 if no entry matches
+	entries bool
 }
 
-// Holds path parameters (corresponding to _ positions)
-var currentPathParams []string
+// Whether a request method is in methods. HEAD is answered like GET
+// (net/http discards the body).
+func methodAllowed(methods []string, method string) bool {
+	for _, m := range methods {
+		if method == m || (configAutoMethods && method == "HEAD" && m == "GET") {
+			return true
+		}
+	}
+	return false
+}
 
 func main() {
+	// Subcommands
+	if len(os.Args) > 1 && os.Args[1] == "new" {
+		runNew(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		runReplay(os.Args[2:])
+		return
+	}
+
 	flag.Parse()
 
     if *showVersion || flag.Lookup("v").Value.(flag.Getter).Get().(bool) {
@@ -52,258 +340,1410 @@ func main() {
         os.Exit(0)
     }
 
+	if *fromOpenAPI != "" {
+		runImportOpenAPI(*fromOpenAPI)
+		return
+	}
+
 	initConfig()
+	defer removeMockArchive()
 
-	log.Printf("[apimock] Starting -> http://localhost:%s", configPort)
-    log.Printf("Mock directory: %s", configDir)
-	
-	entries, _ := os.ReadDir(configDir)
-	for _, e := range entries {
-		if e.IsDir() {
-			log.Printf("  └─ 📁 %s/", e.Name())
-		} else if strings.HasSuffix(e.Name(), ".json") {
-			log.Printf("  ├─ 📄 %s", e.Name())
+	if *seed != 0 {
+		seedRandom(*seed)
+	} else if configDeterministic {
+		seedRandom(1)
+	}
+
+	if *checkMode {
+		runCheck()
+		return
+	}
+	if *showRoutes {
+		printRoutes()
+		return
+	}
+	if *matchRequest != "" {
+		printMatch(*matchRequest)
+		return
+	}
+	if configValidate || configStrict {
+		validateMockFiles(configStrict)
+	}
+
+	if configSocket != "" {
+		log.Printf("[apimock] Starting -> %s on unix:%s (e.g. curl --unix-socket %s %s://localhost/)", serverScheme(), configSocket, configSocket, serverScheme())
+	} else {
+		for _, p := range configPorts {
+			if p.Dir != "" {
+				log.Printf("[apimock] Starting -> %s (serving %s)", serverURL(p.Port), filepath.Join(configDir, p.Dir))
+			} else {
+				log.Printf("[apimock] Starting -> %s", serverURL(p.Port))
+			}
 		}
 	}
+    log.Printf("Mock directory: %s", configDir)
+	
+	logMockTree()
     log.Println("Press Ctrl+C to stop")
 
-    http.HandleFunc("/", mockHandler)
-	log.Fatal(http.ListenAndServe(":"+configPort, nil))
+	if configWatch {
+		startWatcher()
+	}
+
+    serverStart = time.Now()
+    registerAdminRoutes(http.DefaultServeMux)
+    http.HandleFunc("/", recordRequests(withTraceContext(limitConcurrency(mockHandler))))
+	runServers(newServers())
 }
 
 func initConfig() {
-    // Default values
-    configDir = "mock"
-    configPort = "8080"
-
-    // 1. Load config from home directory
-    loadConfigFromPath(os.ExpandEnv("$HOME/.apimockrc"))
-    // 2. Load config from current directory (override)
-    loadConfigFromPath(".apimockrc")
+    loadConfigFiles()
 
     // Override if command line arguments are specified
     if *mockDir != "" {
         configDir = *mockDir
     }
-    if *port != "" {
-        configPort = *port
+    if len(*port) > 0 {
+        configPorts = *port
+        configPort = configPorts[0].Port
+    }
+    if *listenHost != "" {
+        configHost = *listenHost
+    }
+    if *socketPath != "" {
+        configSocket = *socketPath
+    }
+    if *hostRouting {
+        configHostRouting = true
+    }
+    if *noCharset {
+        configNoCharset = true
+    }
+    if isFlagSet("auto-methods") {
+        configAutoMethods = *autoMethods
+    }
+    if isFlagSet("watch") {
+        configWatch = *watch
+    }
+    if *admin {
+        configAdmin = true
+    }
+    if isFlagSet("validate") {
+        configValidate = *validate
+    }
+    if *strict {
+        configStrict = true
+    }
+    if *corsOrigins != "" {
+        configCORSOrigins = splitList(*corsOrigins)
+    }
+    if *staticDir != "" {
+        configStaticDir = *staticDir
+    }
+    if isFlagSet("max-concurrent") {
+        configMaxConcurrent = *maxConcurrent
+    }
+    if *concurrencyMode != "" {
+        configConcurrencyMode = *concurrencyMode
+    }
+    if configMaxConcurrent > 0 {
+        globalSemaphore = make(semaphore, configMaxConcurrent)
+    }
+    if *flagsFile != "" {
+        configFlagsFile = *flagsFile
+    }
+    if *requireHeaders != "" {
+        configRequireHeaders = strings.Split(*requireHeaders, ",")
+    }
+    if *warmupSeconds > 0 {
+        configWarmupSeconds = *warmupSeconds
+    }
+    if *deterministic {
+        configDeterministic = true
+    }
+    if *trailingNewline != "" {
+        configTrailingNewline = *trailingNewline
+    }
+    if *injectMeta != "" {
+        configMetaKey = *injectMeta
+    }
+    if *traceContext {
+        configTraceContext = true
+    }
+    if *requestLog != "" {
+        configRequestLog = *requestLog
+    }
+    if *ignore != "" {
+        configIgnore = append(configIgnore, strings.Split(*ignore, ",")...)
+    }
+    if *logLevel != "" {
+        configLogLevel = *logLevel
+    }
+    if *upstream != "" {
+        configUpstream = *upstream
+    }
+    if *tlsCert != "" {
+        configTLSCert = *tlsCert
+    }
+    if *tlsKey != "" {
+        configTLSKey = *tlsKey
+    }
+    if *tlsSelfSigned {
+        configTLSSelfSigned = true
+    }
+    if *noCompression {
+        configNoCompression = true
+    }
+    if *noETag {
+        configNoETag = true
+    }
+    if *noCORS {
+        configCORS = &CORS{disabled: true}
+    }
+    if *expandEnv {
+        configExpandEnv = true
+    }
+    if *embeddedWild {
+        configEmbeddedWildcards = true
+    }
+    if *caseInsensitive {
+        configCaseInsensitive = true
+    }
+    if *maxRequestBody > 0 {
+        configMaxRequestBody = *maxRequestBody
+    }
+
+    // A .zip file or URL is extracted to a temporary directory
+    if isMockArchive(configDir) {
+        dir, err := extractMockArchive(configDir)
+        if err != nil {
+            removeMockArchive()
+            log.Fatalf("Failed to load mock archive '%s': %v", configDir, err)
+        }
+        log.Printf("[apimock] Extracted %s to %s", configDir, dir)
+        configDir = dir
     }
 
     // Final check
     if info, err := os.Stat(configDir); err != nil || !info.IsDir() {
-        log.Fatalf("Mock directory '%s' not found. Please specify with --dir or write correct path in .apimockrc.", configDir)
+        fatalf("Mock directory '%s' not found. Please specify with --dir or write correct path in .apimockrc.", configDir)
+    }
+    seenPorts := map[string]bool{}
+    for _, p := range configPorts {
+        if seenPorts[p.Port] {
+            fatalf("Port %s is specified more than once.", p.Port)
+        }
+        seenPorts[p.Port] = true
+        if p.Dir == "" {
+            continue
+        }
+        if info, err := os.Stat(filepath.Join(configDir, p.Dir)); err != nil || !info.IsDir() || strings.HasPrefix(p.Dir, "..") {
+            fatalf("Directory '%s' of port %s not found in the mock directory '%s'.", p.Dir, p.Port, configDir)
+        }
+    }
+    if configStaticDir != "" {
+        if info, err := os.Stat(configStaticDir); err != nil || !info.IsDir() {
+            fatalf("Static directory '%s' not found. Please specify with --static or write correct path in .apimockrc.", configStaticDir)
+        }
+    }
+    if configUpstream != "" {
+        u, err := url.Parse(configUpstream)
+        if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+            fatalf("Invalid upstream URL '%s'. Please specify an http(s) base URL with --upstream or upstream in .apimockrc.", configUpstream)
+        }
+        upstreamURL = u
+    }
+    if *record && upstreamURL == nil {
+        fatalf("--record needs an upstream. Please specify it with --upstream or upstream in .apimockrc.")
+    }
+    if configDefaultStatus < 100 || configDefaultStatus > 599 {
+        fatalf("Invalid defaultStatus %d in .apimockrc. Please specify a status code between 100 and 599.", configDefaultStatus)
+    }
+    for status, file := range configErrorFiles {
+        if !errorFileStatuses[status] {
+            fatalf("Invalid errorFiles key '%s' in .apimockrc. Please use 404, 405, 406, 500 or 503.", status)
+        }
+        if _, err := os.Stat(file); err != nil {
+            log.Printf("[WARNING] Error response file for %s: %v", status, err)
+        }
+    }
+    if (configTLSCert == "") != (configTLSKey == "") {
+        fatalf("TLS needs both a certificate and a key. Please specify them with --tls-cert and --tls-key or tlsCert and tlsKey in .apimockrc.")
+    }
+    if configTLSCert != "" && configTLSSelfSigned {
+        fatalf("--tls-self-signed cannot be combined with a TLS certificate file. Please use only one of them.")
+    }
+    configHost = strings.Trim(configHost, "[]")
+    if err := validateListenHost(configHost); err != nil {
+        fatalf("Invalid host '%s': %v. Please specify an IP address or a resolvable name with --host or host in .apimockrc.", configHost, err)
+    }
+    if configSocket != "" && configHost != "" {
+        fatalf("--socket cannot be combined with a host. Please use only one of them.")
+    }
+    if _, ok := logLevels[configLogLevel]; configLogLevel != "" && !ok {
+        fatalf("Unknown log level '%s'. Please specify off, info or debug with --log-level or logLevel in .apimockrc.", configLogLevel)
     }
 }
 
-func loadConfigFromPath(path string) {
+// Expand a leading ~/ to the home directory
+func expandHome(path string) string {
+    if strings.HasPrefix(path, "~/") {
+        home, _ := os.UserHomeDir()
+        return filepath.Join(home, path[2:])
+    }
+    return path
+}
+
+// Entries of a comma-separated flag value, trimmed and without empty ones
+// ("a, b," -> ["a", "b"])
+func splitList(s string) []string {
+    var list []string
+    for _, item := range strings.Split(s, ",") {
+        if item = strings.TrimSpace(item); item != "" {
+            list = append(list, item)
+        }
+    }
+    return list
+}
+
+// Whether a flag was given on the command line
+func isFlagSet(name string) bool {
+    set := false
+    flag.Visit(func(f *flag.Flag) {
+        if f.Name == name {
+            set = true
+        }
+    })
+    return set
+}
+
+// Set default values and load .apimockrc files
+func loadConfigFiles() {
+    // Default values
+    configDir = "mock"
+    configPort = "8080"
+    configPorts = []listenPort{{Port: "8080"}}
+    configForceStatusHeader = "X-Force-Status"
+    configDefaultStatus = 200
+    configAutoMethods = true
+    configWatch = true
+    configValidate = true
+    configLegacyRawFallback = true
+    configConcurrencyMode = "queue"
+
+    // 1. Load config from home directory
+    loadConfigFromPath(os.ExpandEnv("$HOME/.apimockrc"))
+    // 2. Load config from current directory (override)
+    loadConfigFromPath(".apimockrc")
+}
+
+// Read a config file, expanding ${NAME} environment variables. Returns
+// nil (and logs a warning if it is invalid) when there is none to apply.
+func readConfigFile(path string) *Config {
     data, err := os.ReadFile(path)
     if err != nil {
-        return // Ignore if file does not exist
+        return nil // Ignore if file does not exist
     }
 
     var cfg Config
-    if err := json.Unmarshal(data, &cfg); err != nil {
+    if err := json.Unmarshal(expandEnvJSON(data), &cfg); err != nil {
         log.Printf("[WARNING] Failed to parse config file '%s': %v", path, err)
+        return nil
+    }
+    return &cfg
+}
+
+func loadConfigFromPath(path string) {
+    cfg := readConfigFile(path)
+    if cfg == nil {
         return
     }
 
     if cfg.Dir != "" {
-        if strings.HasPrefix(cfg.Dir, "~/") {
-            home, _ := os.UserHomeDir()
-            configDir = filepath.Join(home, cfg.Dir[2:])
-        } else {
-            configDir = cfg.Dir
+        configDir = expandHome(cfg.Dir)
+    }
+    if cfg.ForceStatusHeader != "" {
+        configForceStatusHeader = cfg.ForceStatusHeader
+    }
+    if cfg.DefaultStatus != 0 {
+        configDefaultStatus = cfg.DefaultStatus
+    }
+    if cfg.DefaultHeaders != nil {
+        configDefaultHeaders = cfg.DefaultHeaders
+    }
+    if cfg.ErrorFiles != nil {
+        configErrorFiles = map[string]string{}
+        for status, file := range cfg.ErrorFiles {
+            configErrorFiles[status] = expandHome(file)
         }
     }
+    if cfg.HostRouting {
+        configHostRouting = true
+    }
+    if cfg.NoCharset {
+        configNoCharset = true
+    }
+    if cfg.AutoMethods != nil {
+        configAutoMethods = *cfg.AutoMethods
+    }
+    if cfg.Watch != nil {
+        configWatch = *cfg.Watch
+    }
+    if cfg.Admin {
+        configAdmin = true
+    }
+    if cfg.Validate != nil {
+        configValidate = *cfg.Validate
+    }
+    if cfg.Strict {
+        configStrict = true
+    }
+    if cfg.LegacyRawFallback != nil {
+        configLegacyRawFallback = *cfg.LegacyRawFallback
+    }
+    if cfg.CORSOrigins != nil {
+        configCORSOrigins = cfg.CORSOrigins
+    }
+    if cfg.CORS != nil {
+        configCORS = cfg.CORS
+    }
+    if cfg.Auth != nil {
+        configAuth = cfg.Auth
+    }
+    if cfg.MaxConcurrent > 0 {
+        configMaxConcurrent = cfg.MaxConcurrent
+    }
+    if cfg.ConcurrencyMode != "" {
+        configConcurrencyMode = cfg.ConcurrencyMode
+    }
+    if cfg.Flags != "" {
+        configFlagsFile = expandHome(cfg.Flags)
+    }
+    if cfg.RequireHeaders != nil {
+        configRequireHeaders = cfg.RequireHeaders
+    }
+    if cfg.RequireHeadersExempt != nil {
+        configRequireHeadersExempt = cfg.RequireHeadersExempt
+    }
+    if cfg.RequireHeadersResponse != nil {
+        configRequireHeadersResponse = cfg.RequireHeadersResponse
+    }
+    if cfg.WarmupResponse != nil {
+        configWarmupResponse = cfg.WarmupResponse
+    }
+    if cfg.BodyTooLargeResponse != nil {
+        configBodyTooLargeResponse = cfg.BodyTooLargeResponse
+    }
+    if cfg.RateLimit != nil {
+        configRateLimit = cfg.RateLimit
+    }
+    if cfg.InlineRoutes != nil {
+        configInlineRoutes = cfg.InlineRoutes
+    }
+    if cfg.ClientHeader != "" {
+        configClientHeader = cfg.ClientHeader
+    }
+    if cfg.ClientExpiry > 0 {
+        configClientExpiry = cfg.ClientExpiry
+    }
+    if cfg.WarmupSeconds > 0 {
+        configWarmupSeconds = cfg.WarmupSeconds
+    }
+    if cfg.Deterministic {
+        configDeterministic = true
+    }
+    if cfg.TrailingNewline != "" {
+        configTrailingNewline = cfg.TrailingNewline
+    }
+    if cfg.LogLevel != "" {
+        configLogLevel = cfg.LogLevel
+    }
+    if cfg.Upstream != "" {
+        configUpstream = cfg.Upstream
+    }
+    if cfg.TLSCert != "" {
+        configTLSCert = expandHome(cfg.TLSCert)
+    }
+    if cfg.TLSKey != "" {
+        configTLSKey = expandHome(cfg.TLSKey)
+    }
+    if cfg.TLSSelfSigned {
+        configTLSSelfSigned = true
+    }
+    if cfg.NoCompression {
+        configNoCompression = true
+    }
+    if cfg.NoETag {
+        configNoETag = true
+    }
+    if cfg.ExpandEnv {
+        configExpandEnv = true
+    }
+    if cfg.EmbeddedWildcards {
+        configEmbeddedWildcards = true
+    }
+    if cfg.CaseInsensitive {
+        configCaseInsensitive = true
+    }
+    if cfg.MaxRequestBody > 0 {
+        configMaxRequestBody = cfg.MaxRequestBody
+    }
+    if cfg.InjectMeta != "" {
+        configMetaKey = cfg.InjectMeta
+    }
+    if cfg.TraceContext {
+        configTraceContext = true
+    }
+    if cfg.WatchDirs != nil {
+        configWatchDirs = cfg.WatchDirs
+    }
+    if cfg.WatchDebounce > 0 {
+        configWatchDebounce = cfg.WatchDebounce
+    }
+    if cfg.RequestLog != "" {
+        configRequestLog = expandHome(cfg.RequestLog)
+    }
+    if cfg.Deprecation != nil {
+        configDeprecation = cfg.Deprecation
+    }
+    if cfg.Ignore != nil {
+        configIgnore = cfg.Ignore
+    }
+    if cfg.Static != "" {
+        configStaticDir = expandHome(cfg.Static)
+    }
+    if cfg.Host != "" {
+        configHost = cfg.Host
+    }
+    if cfg.Socket != "" {
+        configSocket = expandHome(cfg.Socket)
+    }
     if cfg.Port != nil {
-        switch v := cfg.Port.(type) {
-        case string:
-            configPort = v
-        case float64:
-            configPort = strconv.Itoa(int(v))
+        ports, err := parsePorts(cfg.Port)
+        if err != nil || len(ports) == 0 {
+            log.Printf("[WARNING] Invalid port in config file '%s': %v", path, err)
+        } else {
+            configPorts = ports
+            configPort = ports[0].Port
         }
     }
 }
 
 func mockHandler(w http.ResponseWriter, r *http.Request) {
-	if r.URL.Path == "/" {
+	alog, w := newAccessLog(w, r)
+	defer alog.finish()
+	r = withParsedBody(r)
+
+	if configDeterministic {
+		w.Header().Set("Date", pinnedTime.Format(http.TimeFormat))
+	}
+	// Before CORS, which replaces any Access-Control-* header set here
+	for k, v := range configDefaultHeaders {
+		w.Header().Set(k, v)
+	}
+
+	if r.URL.Path == "/" && !hasRootMock(r) {
+		if configStaticDir != "" && serveStatic(w, r) {
+			return
+		}
+		if *browse && serveListing(w, r, requestBaseDir(r), "") {
+			return
+		}
 		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 		w.Write([]byte("apimock server is running!"))
 		return
 	}
 
-	// Allow all CORS
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET,POST,PUT,DELETE,OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "*")
-	if r.Method == "OPTIONS" {
+	requestPath := normalizeRequestPath(r.URL.Path)
+
+    baseDir := requestBaseDir(r)
+
+	applyCORS(w, r, configCORS)
+	// Without autoMethods or CORS, OPTIONS is matched like any other method
+	if r.Method == "OPTIONS" && configAutoMethods && !corsDisabled() {
+		applyCORS(w, r, preflightCORS(r, baseDir, requestPath))
 		w.WriteHeader(200)
 		return
 	}
 
-	requestPath := strings.TrimPrefix(r.URL.Path, "/")
+	if !checkWarmup(w, configWarmupSeconds) {
+		return
+	}
+	if !checkRequiredHeaders(w, r) {
+		return
+	}
+	if configRateLimit != nil && !checkRateLimit(w, "", configRateLimit, newTemplateData(r, nil)) {
+		return
+	}
+
+	// Inline routes from .apimockrc take precedence over files
+	mock, filePath, pathParams, inline := matchInlineRoute(requestPath)
+	if !inline {
+		var ok bool
+		if mock, filePath, pathParams, ok = loadMockFile(w, r, baseDir, requestPath); !ok {
+			return
+		}
+	}
+	alog.route = routeKey(filePath)
+	alog.setLevel(mock.LogLevel, filePath)
+	alog.debugf("matched %s (path params: %v)", alog.route, pathParams)
+	if mock.CORS != nil {
+		applyCORS(w, r, mock.CORS)
+	}
 
-    filePath, pathParams := findBestMockFile(configDir, requestPath)
-    currentPathParams = pathParams
+	// Route-level warm-up
+	if !checkWarmup(w, mock.WarmupSeconds) {
+		return
+	}
+
+	// Select the current version
+	if len(mock.Versions) > 0 {
+		mock = selectVersion(mock, routeKey(filePath), r)
+	}
+
+	// Select the next response of a sequence
+	if len(mock.Sequence) > 0 {
+		mock = selectSequence(mock, routeKey(filePath), filePath)
+	}
+
+	// Check method
+	if len(mock.Method) > 0 {
+		if !methodAllowed(mock.Method, r.Method) {
+			respondError(w, r, 405, map[string]string{
+				"error": "Method Not Allowed",
+				"allow": strings.Join(mock.Method, ", "),
+			})
+			return
+		}
+	}
+
+	// Check credentials (the mock's own auth replaces the global one)
+	auth := configAuth
+	if mock.Auth != nil {
+		auth = mock.Auth
+	}
+	if !checkAuth(w, r, auth) {
+		return
+	}
+
+	// Check the size of the request body
+	if !checkBodyLimit(w, r, mock) {
+		return
+	}
+
+	// Check rate limit
+	if mock.RateLimit != nil && !checkRateLimit(w, routeKey(filePath), mock.RateLimit, newTemplateData(r, pathParams).withRoute(filePath)) {
+		return
+	}
+
+	// Check concurrency limit
+	if c := mock.Concurrency; c != nil && c.Max > 0 {
+		sem := routeSemaphore(routeKey(filePath), c.Max)
+		if !sem.acquire(r, c.Mode == "reject") {
+			respondError(w, r, 503, map[string]string{"error": "Service Unavailable"})
+			return
+		}
+		defer sem.release()
+	}
+
+	// Handle Expect: 100-continue
+	if mock.Continue != "" && expectsContinue(r) {
+		switch mock.Continue {
+		case "send":
+			// Send the interim response explicitly and consume the body
+			w.WriteHeader(http.StatusContinue)
+			if _, err := readBody(r); respondBodyTooLarge(w, err) {
+				return
+			}
+		case "reject":
+			// Never touch the body so net/http does not send 100 Continue
+			r.Body = http.NoBody
+		default:
+			log.Printf("[WARNING] Unknown continue value '%s' in %s", mock.Continue, filePath)
+		}
+	}
+
+	// A top-level matchBody restricts the whole file
+	if mock.MatchBody != nil {
+		if _, err := readBody(r); respondBodyTooLarge(w, err) {
+			return
+		}
+		if !bodyMatches(mock.MatchBody, r) {
+			alog.debugf("request body does not match matchBody")
+			respondError(w, r, 404, map[string]string{"error": "Not Found"})
+			return
+		}
+	}
+
+	// Likewise a top-level matchCookies
+	if mock.MatchCookies != nil && !cookiesMatch(mock.MatchCookies, r) {
+		alog.debugf("request cookies do not match matchCookies")
+		respondError(w, r, 404, map[string]string{"error": "Not Found"})
+		return
+	}
+
+	// A top-level matchForm restricts the whole file, too
+	if mock.MatchForm != nil {
+		if _, err := readBody(r); respondBodyTooLarge(w, err) {
+			return
+		}
+		if !formMatches(mock.MatchForm, r) {
+			alog.debugf("request form does not match matchForm")
+			respondError(w, r, 404, map[string]string{"error": "Not Found"})
+			return
+		}
+	}
+
+	// Select a variant matching the request
+	mc := matchContext{pathParams: pathParams}
+	if mock.Experiment != nil {
+		mc.bucket = assignBucket(w, r, mock.Experiment)
+		alog.debugf("experiment bucket %s", mc.bucket)
+	}
+	if variantsCountClients(mock) {
+		mc.priorRequests = clientState.visit(routeKey(filePath), clientKey(r))
+		alog.debugf("client %s made %d prior request(s)", clientKey(r), mc.priorRequests)
+	}
+	if len(mock.Variants) > 0 {
+		// Body matchers need the whole body
+		if variantsReadBody(mock) {
+			if _, err := readBody(r); respondBodyTooLarge(w, err) {
+				return
+			}
+		}
+		var matched bool
+		mock, matched = selectVariant(mock, r, filePath, mc)
+		alog.debugf("variant matched: %v", matched)
+		if !matched && mock.entries {
+			respondError(w, r, 404, map[string]string{"error": "Not Found"})
+			return
+		}
+	}
+
+	// Pick the body the Accept header asks for
+	if len(mock.Representations) > 0 {
+		addVary(w.Header(), "Accept")
+		rep, ok := selectRepresentation(mock.Representations, r.Header.Get("Accept"))
+		if !ok {
+			respondError(w, r, 406, map[string]interface{}{"error": "Not Acceptable", "available": mock.Representations.mediaTypes()})
+			return
+		}
+		alog.debugf("representation %s", rep.MediaType)
+		mock = rep.apply(mock)
+	}
+
+	// Use a shared body from the registry
+	if mock.BodyRef != "" {
+		body, err := loadBodyRef(mock.BodyRef)
+		if err != nil {
+			log.Printf("[WARNING] bodyRef failed for %s: %v", filePath, err)
+			respondError(w, r, 500, map[string]string{"error": "Server Error"})
+			return
+		}
+		mock.Body = body
+	}
+
+	// Splice in $include files
+	included, err := expandIncludes(mock.Body)
+	if err != nil {
+		log.Printf("[WARNING] $include failed for %s: %v", filePath, err)
+		respondError(w, r, 500, map[string]string{"error": "Server Error"})
+		return
+	}
+	mock.Body = included
+
+	// Compute the body from CSV data
+	if mock.CSV != nil {
+		body, found, err := csvBody(mock.CSV, r, pathParams)
+		if err != nil {
+			log.Printf("[WARNING] csv failed for %s: %v", filePath, err)
+			respondError(w, r, 500, map[string]string{"error": "Server Error"})
+			return
+		}
+		if !found {
+			respondError(w, r, 404, map[string]string{"error": "Not Found"})
+			return
+		}
+		mock.Body = body
+	}
+
+	// Handle delay
+	if mock.Delay.Max > 0 {
+		d := mock.Delay.duration()
+		alog.debugf("delay %dms", d.Milliseconds())
+		sleepDelay(d)
+	}
+
+	// status (default 200 or defaultStatus)
+	status := mock.Status
+	explicitStatus := status != 0
+	if status == 0 {
+		status = configDefaultStatus
+	}
+
+	// Status forced by the client (only for mocks that opt in)
+	if mock.ForceStatus {
+		if v := r.Header.Get(configForceStatusHeader); v != "" {
+			if code, err := strconv.Atoi(v); err == nil && code >= 200 && code <= 599 {
+				status, explicitStatus = code, true
+			} else {
+				log.Printf("[WARNING] Ignoring invalid %s: %s", configForceStatusHeader, v)
+			}
+		}
+	}
+
+	if mock.Redirect != nil {
+		status = mock.Redirect.status()
+	}
+
+	// Render headers and body (from the cache if enabled)
+	var res renderedResponse
+	if mock.CacheTTL > 0 {
+		res = renderCached(mock, filePath, r, pathParams)
+	} else {
+		res = renderResponse(mock, filePath, r, pathParams)
+	}
+	if res.err != nil {
+		log.Printf("[WARNING] %s: %v", filePath, res.err)
+		respondError(w, r, 500, map[string]string{"error": res.err.Error()})
+		return
+	}
+
+	// Set headers
+	for k, v := range res.headers {
+		w.Header().Set(k, v)
+	}
+	for _, c := range res.cookies {
+		http.SetCookie(w, c)
+	}
+
+	// Binary bodyFiles are sent from disk as they are
+	if res.file != "" {
+		writeBodyFile(w, r, status, res.file)
+		return
+	}
+
+	// If body is empty -> 204 unless a status was given, or empty JSON
+	if mock.RawBody == nil && mock.BodyFile == "" && mock.Protobuf == nil && (len(res.body) == 0 || res.body == "null") {
+		if status == 200 && !explicitStatus {
+			status = 204
+		}
+		w.WriteHeader(status)
+		return
+	}
+
+	// Optional _meta for performance debugging (never for raw bodies).
+	// Its processing time changes on every request, so no ETag is computed.
+	withMeta := false
+	if configMetaKey != "" && mock.RawBody == nil && mock.BodyFile == "" && mock.Protobuf == nil {
+		body := addResponseMeta(res.body, alog.start, alog.route)
+		withMeta = body != res.body
+		res.body = body
+	}
+
+	// An explicit Content-Type in headers is used verbatim
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", jsonContentType())
+	}
+	// JSONP clients cannot see the status, so errors are sent as 200, too
+	callback, ok := jsonpCallback(w, r, mock)
+	if !ok {
+		return
+	}
+	if callback != "" {
+		res.body = wrapJSONP(w, callback, res.body)
+		status = 200
+	}
+	// Streams are never compressed, so each chunk reaches the client as is
+	if mock.Stream != nil {
+		writeStream(w, r, status, mock.Stream, res.body)
+		return
+	}
+	if writeNotModified(w, r, status, res.body, !withMeta) {
+		return
+	}
+	body := compressBody(w, r, []byte(res.body))
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// Find and parse the mock file for a request. Returns false if a
+// response has already been written (not found, fixture, raw file, error).
+func loadMockFile(w http.ResponseWriter, r *http.Request, baseDir, requestPath string) (mock MockResponse, filePath string, pathParams []string, ok bool) {
+	lookup := findMockFile(baseDir, requestPath, r.Method, r.URL.Query())
+	filePath, pathParams = lookup.path, lookup.params
 
 	// 404 if file not found
 	if filePath == "" {
-		respondJSON(w, 404, map[string]string{"error": "Not Found"})
+		// Only files for other methods (users.POST.json for GET /users),
+		// unless other methods are forwarded to the upstream
+		if len(lookup.methods) > 0 && upstreamURL == nil {
+			respondError(w, r, 405, map[string]string{
+				"error": "Method Not Allowed",
+				"allow": strings.Join(lookup.methods, ", "),
+			})
+			return
+		}
+		if *browse && serveListing(w, r, baseDir, requestPath) {
+			return
+		}
+		if configStaticDir != "" && serveStatic(w, r) {
+			return
+		}
+		if upstreamURL != nil {
+			proxyUpstream(w, r, baseDir)
+			return
+		}
+		if *suggest {
+			respondError(w, r, 404, map[string]interface{}{
+				"error":         "Not Found",
+				"requestedPath": "/" + requestPath,
+				"suggestions":   suggestRoutes(requestPath),
+			})
+			return
+		}
+		respondError(w, r, 404, map[string]string{"error": "Not Found"})
+		return
+	}
+
+	// Precompressed fixtures are served as is
+	if isGzipFixture(filePath) {
+		serveGzipFixture(w, r, filePath)
 		return
 	}
 
 	// Parse JSON file
 	file, err := os.Open(filePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		// Removed or renamed since it was matched: resolve the route again
+		log.Printf("[WARNING] %s disappeared after matching, resolving %s again", filePath, r.URL.Path)
+		filePath, pathParams = findBestMockFile(baseDir, requestPath, r.Method, r.URL.Query())
+		if filePath == "" {
+			respondError(w, r, 404, map[string]string{"error": "Not Found"})
+			return
+		}
+		if isGzipFixture(filePath) {
+			serveGzipFixture(w, r, filePath)
+			return
+		}
+		file, err = os.Open(filePath)
+	}
 	if err != nil {
-		respondJSON(w, 500, map[string]string{"error": "Server Error"})
+		log.Printf("[ERROR] Cannot open %s: %v", filePath, err)
+		if errors.Is(err, fs.ErrNotExist) {
+			respondError(w, r, 404, map[string]string{"error": "Not Found"})
+		} else {
+			respondError(w, r, 500, map[string]string{"error": "Server Error"})
+		}
 		return
 	}
 	defer file.Close()
 
 	data, _ := io.ReadAll(file)
-	var mock MockResponse
+	if isYAMLFile(filePath) {
+		if data, err = yamlToJSON(data); err != nil {
+			log.Printf("[WARNING] %s: %v", filePath, err)
+			respondError(w, r, 500, map[string]string{"error": "Invalid YAML: " + err.Error()})
+			return
+		}
+	}
+	if data, err = withDefaults(filePath, data); err != nil {
+		log.Printf("[WARNING] %s: %v", filePath, err)
+		respondError(w, r, 500, map[string]string{"error": "Invalid defaults: " + err.Error()})
+		return
+	}
+	if configExpandEnv {
+		data = expandEnvJSON(data)
+	}
+	if entries, ok := parseMockEntries(data); ok {
+		return MockResponse{Variants: entries, entries: true}, filePath, pathParams, true
+	}
 	if err := json.Unmarshal(data, &mock); err != nil {
+		// Simple mode arrays are always served; broken files only with
+		// legacyRawFallback
+		if !configLegacyRawFallback && !isSimpleModeBody(data) {
+			log.Printf("[ERROR] %s: %v", filePath, err)
+			respondError(w, r, 500, map[string]string{"error": "Invalid mock file: " + err.Error()})
+			return
+		}
 		// Parse failed -> return as raw JSON with 200 (compatibility with old method)
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(200)
 		w.Write(data)
 		return
 	}
+	if *strictFields {
+		if err := unknownFieldError(data); err != nil {
+			log.Printf("[WARNING] %s: %v", filePath, err)
+		}
+	}
+	return mock, filePath, pathParams, true
+}
 
-	// Check method
-	if len(mock.Method) > 0 {
-		allowed := false
-		for _, m := range mock.Method {
-			if r.Method == m {
-				allowed = true
-				break
+// Headers and body after template expansion
+type renderedResponse struct {
+	headers map[string]string
+	cookies []*http.Cookie
+	body    string
+	file    string // bodyFile sent as is (body is empty)
+	err     error  // Set if the body could not be encoded
+}
+
+func renderResponse(mock MockResponse, filePath string, r *http.Request, pathParams []string) renderedResponse {
+	td := newTemplateData(r, pathParams).withRoute(filePath)
+	td.trace = requestTrace(r)
+	res := renderedResponse{headers: map[string]string{}}
+
+	// The body is rendered first so headers can refer to it
+	if mock.BodyFile != "" {
+		p, err := bodyFilePath(mock.BodyFile)
+		if err != nil {
+			res.err = fmt.Errorf("bodyFile failed: %v", err)
+			return res
+		}
+		ct := headerValue(mock.Headers, "Content-Type")
+		if ct == "" {
+			if ct, err = bodyFileContentType(p); err != nil {
+				res.err = fmt.Errorf("bodyFile failed: %v", err)
+				return res
 			}
+			res.headers["Content-Type"] = ct
 		}
-		if !allowed {
-			respondJSON(w, 405, map[string]string{
-				"error": "Method Not Allowed",
-				"allow": strings.Join(mock.Method, ", "),
-			})
-			return
+		// Templates are expanded in text files only; other files are
+		// streamed unless a header needs the body
+		if !isTextContentType(ct) && !headersUseBody(mock.Headers) {
+			res.file = p
+		} else if data, err := os.ReadFile(p); err != nil {
+			res.err = fmt.Errorf("bodyFile failed: %v", err)
+			return res
+		} else if strings.Contains(ct, "json") && json.Valid(data) {
+			res.body = td.expandJSON(string(data))
+		} else if isTextContentType(ct) {
+			res.body = td.expand(string(data))
+		} else {
+			res.body = string(data)
+		}
+	} else if mock.RawBody != nil {
+		// Raw bodies are never touched, unless they opt in to templates
+		res.body = *mock.RawBody
+		if mock.Template {
+			if res.body, res.err = renderTemplate(res.body); res.err != nil {
+				res.err = fmt.Errorf("template failed: %v", res.err)
+				return res
+			}
+		}
+	} else {
+		// Fill missing properties from the schema
+		if mock.SchemaFill != "" {
+			if filled, err := fillFromSchema(mock.Body, mock.SchemaFill); err != nil {
+				log.Printf("[WARNING] schemaFill failed for %s: %v", filePath, err)
+			} else {
+				mock.Body = filled
+			}
+		}
+
+		// Generate $repeat arrays
+		mock.Body = expandRepeats(mock.Body, r, td)
+
+		// Templates first, so values from the request are never run as templates
+		if mock.Template {
+			rendered, err := renderTemplateJSON(string(mock.Body))
+			if err != nil {
+				res.err = fmt.Errorf("template failed: %v", err)
+				return res
+			}
+			mock.Body = json.RawMessage(rendered)
+		}
+
+		// Replace {path.x}, {query.x}, ... with actual values
+		if len(mock.Body) > 0 && string(mock.Body) != "null" {
+			res.body = td.expandJSON(string(mock.Body))
+		}
+
+		if mock.Protobuf == nil && configTrailingNewline != "" {
+			res.body = adjustTrailingNewline(res.body, headerValue(mock.Headers, "Content-Type"))
+		}
+
+		if mock.Protobuf != nil {
+			encoded, err := mock.Protobuf.encode(res.body)
+			if err != nil {
+				res.err = fmt.Errorf("protobuf encoding failed: %v", err)
+				return res
+			}
+			res.body = encoded
+			res.headers["Content-Type"] = "application/x-protobuf"
 		}
 	}
 
-	// Handle delay
-	if mock.Delay > 0 {
-		time.Sleep(time.Duration(mock.Delay) * time.Millisecond)
+	// Deprecation headers come first so explicit headers can override them
+	deprecation := configDeprecation
+	if mock.Deprecation != nil {
+		deprecation = mock.Deprecation
+	}
+	if deprecation != nil {
+		for k, v := range deprecation.headers(td) {
+			res.headers[k] = v
+		}
 	}
 
-	// Set headers
+	// Can expand {path.x} and {body.length}/{body.sha256} in headers as well
 	for k, v := range mock.Headers {
-		// Can expand {path.x} in headers as well
-        v = replacePathParams(v)
-        w.Header().Set(k, v)
+		if mock.Template {
+			rendered, err := renderTemplate(v)
+			if err != nil {
+				res.err = fmt.Errorf("template failed in header %s: %v", k, err)
+				return res
+			}
+			v = rendered
+		}
+		res.headers[k] = td.expand(replaceBodyTokens(v, res.body))
 	}
-
-	// status (default 200)
-	status := mock.Status
-	if status == 0 {
-		status = 200
+	for _, c := range mock.Cookies {
+		res.cookies = append(res.cookies, c.httpCookie(td))
 	}
 
-	// If body is empty -> 204 or empty JSON
-	if len(mock.Body) == 0 || string(mock.Body) == "null" {
-		if status == 200 {
-			status = 204
+	if mock.Redirect != nil {
+		if location, err := mock.Redirect.location(r, td); err != nil {
+			log.Printf("[WARNING] Invalid redirect URL in %s: %v", filePath, err)
+		} else {
+			res.headers["Location"] = location
 		}
-		w.WriteHeader(status)
-		return
 	}
+	return res
+}
 
-	// Replace {path.x} with actual values
-    replacedBody := replacePathParams(string(mock.Body))
+// Mock directory for a Host header: hosts/<host>/, hosts/<subdomain>/,
+// hosts/_/ (default host) or configDir itself, whichever exists first
+func hostDir(root, host string) string {
+    if h, _, err := net.SplitHostPort(host); err == nil {
+        host = h
+    }
+    host = strings.ToLower(host)
+    sub, _, _ := strings.Cut(host, ".")
 
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.WriteHeader(status)
-	w.Write([]byte(replacedBody))
+    for _, name := range []string{host, sub, "_"} {
+        if name == "" {
+            continue
+        }
+        dir := filepath.Join(root, "hosts", name)
+        if info, err := os.Stat(dir); err == nil && info.IsDir() {
+            return dir
+        }
+    }
+    return root
 }
 
-// Replace path parameters
-func replacePathParams(s string) string {
-    re := regexp.MustCompile(`\{path\.(\d+)\}`)
-    return re.ReplaceAllStringFunc(s, func(match string) string {
-        if idxStr := re.FindStringSubmatch(match)[1]; idxStr != "" {
-            if idx, err := strconv.Atoi(idxStr); err == nil && idx < len(currentPathParams) {
-                return currentPathParams[idx]
-            }
+// Identify a route by its mock file path relative to configDir (e.g. "users/_.json")
+func routeKey(filePath string) string {
+	if strings.HasPrefix(filePath, inlinePrefix) {
+		return filePath
+	}
+	rel, err := filepath.Rel(configDir, filePath)
+	if err != nil {
+		return filePath
+	}
+	return filepath.ToSlash(rel)
+}
+
+// Artificial delay (skipped with --no-delay)
+func sleepDelay(d time.Duration) {
+	if d > 0 && !*noDelay {
+		time.Sleep(d)
+	}
+}
+
+// Maximum request body size read for matching (without maxRequestBody)
+const maxBodySize = 10 << 20
+
+// Read the request body (up to bodyLimit) and keep it readable for the
+// next caller
+func readBody(r *http.Request) ([]byte, error) {
+	orig := r.Body
+	limit := bodyLimit(r)
+	data, err := io.ReadAll(io.LimitReader(orig, limit+1))
+	var body io.ReadCloser = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(data), orig), orig}
+	if _, ok := orig.(limitedBody); ok {
+		body = limitedBody{body, limit}
+	}
+	r.Body = body
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, &http.MaxBytesError{Limit: limit}
+	}
+	return data, nil
+}
+
+// Answer a request whose body exceeds the limit. The rest of the body
+// is never read, so the connection is closed afterwards.
+func respondBodyTooLarge(w http.ResponseWriter, err error) bool {
+	var tooLarge *http.MaxBytesError
+	if !errors.As(err, &tooLarge) {
+		return false
+	}
+	w.Header().Set("Connection", "close")
+	writeErrorResponse(w, configBodyTooLargeResponse, http.StatusRequestEntityTooLarge,
+		map[string]string{"error": "Request Entity Too Large"},
+		"{limit}", strconv.FormatInt(tooLarge.Limit, 10))
+	return true
+}
+
+// Whether the client is waiting for 100 Continue before sending the body
+func expectsContinue(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Expect"), "100-continue")
+}
+
+// Mock files are .json or .yaml/.yml files, or gzip-precompressed
+// .json.gz fixtures
+func isMockFile(name string) bool {
+    return (strings.HasSuffix(name, ".json") || isYAMLFile(name) || isGzipFixture(name)) && !isDefaultsFile(name)
+}
+
+func isGzipFixture(name string) bool {
+    return strings.HasSuffix(name, ".json.gz")
+}
+
+// File name without the mock file extension
+func trimMockExt(name string) string {
+    for _, ext := range []string{".json.gz", ".json", ".yaml", ".yml"} {
+        if strings.HasSuffix(name, ext) {
+            return strings.TrimSuffix(name, ext)
         }
-        return match // Return as is if replacement fails
-    })
+    }
+    return name
 }
 
-// Find the best mock file (supports wildcards)
-func findBestMockFile(baseDir, requestPath string) (string, []string) {
-    requestParts := strings.Split(requestPath, "/")
+// Preference among files for the same route: .json, then .yaml/.yml,
+// then .json.gz fixtures
+func mockFormatRank(name string) int {
+    switch {
+    case isGzipFixture(name):
+        return 0
+    case isYAMLFile(name):
+        return 1
+    }
+    return 2
+}
+
+// Separates a label in a mock file name (users~admin.json), which lets
+// several files with different query constraints serve the same route
+const queryLabelSep = "~"
 
-    var bestMatch string
-    var bestParams []string
-    var bestScore int = -1 // The more _ there are, the lower the score (specific = fewer _ is prioritized)
+// Query constraints declared by a mock file (nil if none or unreadable)
+func mockQuery(path string) map[string]string {
+    if isGzipFixture(path) {
+        return nil
+    }
+    data, err := readMockFile(path)
+    if err != nil {
+        return nil
+    }
+    var mock struct {
+        Query map[string]string `json:"query"`
+    }
+    if json.Unmarshal(data, &mock) != nil {
+        return nil
+    }
+    return mock.Query
+}
 
-    err := filepath.WalkDir(baseDir, func(path string, d os.DirEntry, err error) error {
-        if err != nil || d.IsDir() {
-            return err
+// Whether every declared query parameter has the declared value
+func queryMatches(want map[string]string, query url.Values) bool {
+    for name, value := range want {
+        found := false
+        for _, v := range query[name] {
+            if v == value {
+                found = true
+                break
+            }
         }
-        if !strings.HasSuffix(path, ".json") {
-            return nil
+        if !found {
+            return false
         }
+    }
+    return true
+}
+
+// HTTP methods recognized in mock file names (users.GET.json)
+var fileMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
 
-        // Handle index.json
-        rel := strings.TrimSuffix(path, ".json")
-        if strings.HasSuffix(rel, "/index") {
-            rel = strings.TrimSuffix(rel, "/index")
+// Route name and method of a mock file name: "users~admin.GET.json" ->
+// ("users", "GET"). The method is empty for files serving any method.
+func splitMockName(name string) (route, method string) {
+    route = trimMockExt(name)
+    if i := strings.LastIndex(route, "."); i >= 0 {
+        for _, m := range fileMethods {
+            if route[i+1:] == m {
+                route, method = route[:i], m
+                break
+            }
         }
-        rel, _ = filepath.Rel(baseDir, rel)
+    }
+    route, _, _ = strings.Cut(route, queryLabelSep)
+    return route, method
+}
 
-        mockParts := strings.Split(rel, "/")
+// Name of the catch-all wildcard, which matches one or more trailing
+// segments (files/__.json serves /files/a/b/c)
+const catchAllName = "__"
 
-        if len(mockParts) != len(requestParts) {
-            return nil
+// Match a mock file against the request path segments. Returns the
+// wildcard values, the score (fewer _ is more specific; a catch-all
+// scores below every other match) and the method in the file name.
+func matchMockPath(baseDir, path string, requestParts []string) (params []string, score int, method string, ok bool) {
+    dir, name := filepath.Split(path)
+    name, method = splitMockName(name)
+    rel := dir + name
+    // Handle index.json
+    if strings.HasSuffix(rel, "/index") {
+        rel = strings.TrimSuffix(rel, "/index")
+    }
+    rel, _ = filepath.Rel(baseDir, rel)
+    if rel == "." {
+        rel = "" // index.json of the mock directory serves /
+    }
+
+    mockParts := strings.Split(rel, "/")
+    catchAll := mockParts[len(mockParts)-1] == catchAllName
+    if catchAll {
+        mockParts = mockParts[:len(mockParts)-1]
+        if len(requestParts) <= len(mockParts) {
+            return nil, 0, "", false
         }
+    } else if len(mockParts) != len(requestParts) {
+        return nil, 0, "", false
+    }
 
-        var params []string
-        match := true
-        underscoreCount := 0
-
-        for i := range mockParts {
-            if mockParts[i] == "_" {
-                if requestParts[i] == "" {
-                    match = false
-                    break
-                }
-                params = append(params, requestParts[i])
-                underscoreCount++
-            } else if mockParts[i] != requestParts[i] {
-                match = false
-                break
+    // A literal segment scores 2, one with an embedded _ (users-_, with
+    // --embedded-wildcards) or a regexp (_:[0-9]+) 1 and a whole _ segment 0
+    score = 0
+    for i := range mockParts {
+        switch {
+        case mockParts[i] == "_":
+            if requestParts[i] == "" {
+                return nil, 0, "", false
+            }
+            params = append(params, requestParts[i])
+        case isRegexpSegment(mockParts[i]):
+            if !matchSegmentRegexp(mockParts[i], requestParts[i]) {
+                return nil, 0, "", false
             }
+            params = append(params, requestParts[i])
+            score++
+        case segmentEqual(mockParts[i], requestParts[i]):
+            score += 2
+        case configEmbeddedWildcards && strings.Contains(mockParts[i], "_") && strings.Trim(mockParts[i], "_") != "":
+            captured, ok := matchSegmentPattern(mockParts[i], requestParts[i])
+            if !ok {
+                return nil, 0, "", false
+            }
+            params = append(params, captured...)
+            score++
+        default:
+            return nil, 0, "", false
+        }
+    }
+    if catchAll {
+        rest := strings.Join(requestParts[len(mockParts):], "/")
+        if rest == "" {
+            return nil, 0, "", false
         }
+        // Below 0, the lowest score of a match without catch-all
+        return append(params, rest), score - 2*len(requestParts) - 1, method, true
+    }
+    return params, score, method, true
+}
 
-        if match {
-            score := len(requestParts) - underscoreCount // Fewer _ means higher score
-            if score > bestScore {
-                bestScore = score
-                bestMatch = path
-                bestParams = params
-            }
+// Find the best mock file (supports wildcards, method-specific files and
+// query constraints)
+func findBestMockFile(baseDir, requestPath, method string, query url.Values) (string, []string) {
+    m := findMockFile(baseDir, requestPath, method, query)
+    return m.path, m.params
+}
+
+// Result of looking up the mock file for a request
+type mockLookup struct {
+    path    string   // Best matching file ("" if none)
+    params  []string // Values of its _ segments
+    methods []string // Methods of the method-specific files for the path (for 405 when none is for the request method)
+}
+
+func findMockFile(baseDir, requestPath, method string, query url.Values) mockLookup {
+    requestParts := strings.Split(requestPath, "/")
+
+    var best mockLookup
+    var bestScore int = math.MinInt // The more _ there are, the lower the score (specific = fewer _ is prioritized)
+    var bestExact int               // On the same score, a file named for the method wins (HEAD: users.HEAD.json over users.GET.json)
+    var bestQuery int               // Then more matched query constraints win
+    seen := map[string]bool{}
+
+    err := walkMockFiles(baseDir, func(path string) {
+        // Skip routes disabled through the admin API
+        if isRouteDisabled(routeKey(path)) {
+            return
         }
 
-        return nil
+        params, score, fileMethod, match := matchMockPath(baseDir, path, requestParts)
+        if match && fileMethod != "" && !seen[fileMethod] {
+            seen[fileMethod] = true
+            best.methods = append(best.methods, fileMethod)
+        }
+        if !match || score < bestScore {
+            return
+        }
+        if fileMethod != "" && !methodAllowed([]string{fileMethod}, method) {
+            return
+        }
+        exact := 0
+        if fileMethod == method {
+            exact = 2
+        } else if fileMethod != "" {
+            exact = 1
+        }
+        if score == bestScore && exact < bestExact {
+            return
+        }
+        // Only candidates matching the path are parsed for query constraints
+        want := mockQuery(path)
+        if !queryMatches(want, query) {
+            return
+        }
+        if score == bestScore && exact == bestExact && len(want) == bestQuery && trimMockExt(path) == trimMockExt(best.path) {
+            warnFormatConflict(path, best.path)
+        }
+        // On a tie, .json wins over .yaml and both over a .json.gz fixture
+        if score > bestScore || exact > bestExact || len(want) > bestQuery ||
+            (len(want) == bestQuery && mockFormatRank(path) > mockFormatRank(best.path)) {
+            bestScore = score
+            bestExact = exact
+            bestQuery = len(want)
+            best.path = path
+            best.params = params
+        }
     })
 
     if err != nil {
         log.Printf("Walk error: %v", err)
     }
 
-    return bestMatch, bestParams
+    sort.Strings(best.methods)
+    return best
+}
+
+// Default Content-Type of JSON responses
+func jsonContentType() string {
+	if configNoCharset {
+		return "application/json"
+	}
+	return "application/json; charset=utf-8"
 }
 
 func respondJSON(w http.ResponseWriter, status int, body interface{}) {
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Content-Type", jsonContentType())
 	w.WriteHeader(status)
 	json.NewEncoder(w).Encode(body)
 }
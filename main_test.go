@@ -0,0 +1,219 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Serve a temporary mock directory holding files (path relative to the
+// directory -> content) with the default configuration
+func newMockDir(t *testing.T, files map[string]string) string {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	loadConfigFiles()
+	configDir = dir
+	return dir
+}
+
+// Set a configuration variable for the duration of the test
+func setConfig[T any](t *testing.T, p *T, v T) {
+	t.Helper()
+	old := *p
+	*p = v
+	t.Cleanup(func() { *p = old })
+}
+
+func newRequest(method, target, body string, headers ...string) *http.Request {
+	var r io.Reader
+	if body != "" {
+		r = strings.NewReader(body)
+	}
+	req := httptest.NewRequest(method, target, r)
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Add(headers[i], headers[i+1])
+	}
+	return req
+}
+
+// Response of mockHandler for a request
+func serve(t *testing.T, req *http.Request) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	mockHandler(rec, req)
+	return rec
+}
+
+func TestRootMock(t *testing.T) {
+	dir := newMockDir(t, map[string]string{
+		"index.json": `{"body": {"root":true}}`,
+		"users.json": `{"body": {"users":[]}}`,
+	})
+
+	rec := serve(t, newRequest("GET", "/", ""))
+	if rec.Code != 200 || strings.TrimSpace(rec.Body.String()) != `{"root":true}` {
+		t.Errorf("GET / = %d %q, want the root mock", rec.Code, rec.Body.String())
+	}
+	rec = serve(t, newRequest("GET", "/users", ""))
+	if strings.TrimSpace(rec.Body.String()) != `{"users":[]}` {
+		t.Errorf("GET /users = %q, want users.json", rec.Body.String())
+	}
+
+	os.Remove(filepath.Join(dir, "index.json"))
+	rec = serve(t, newRequest("GET", "/", ""))
+	if rec.Code != 200 || !strings.Contains(rec.Body.String(), "running") {
+		t.Errorf("GET / without index.json = %d %q, want the status message", rec.Code, rec.Body.String())
+	}
+}
+
+func TestRouteToFile(t *testing.T) {
+	tests := []struct {
+		route, file string
+		params      []string
+	}{
+		{"/", "index.json", nil},
+		{"/users", "users.json", nil},
+		{"/users/:id", filepath.Join("users", "_.json"), []string{"id"}},
+		{"/users/{id}/posts/_", filepath.Join("users", "_", "posts", "_.json"), []string{"id", "param1"}},
+	}
+	for _, tt := range tests {
+		file, params := routeToFile(tt.route)
+		if file != tt.file || strings.Join(params, ",") != strings.Join(tt.params, ",") {
+			t.Errorf("routeToFile(%q) = %q, %v; want %q, %v", tt.route, file, params, tt.file, tt.params)
+		}
+	}
+}
+
+func TestMethodFiles(t *testing.T) {
+	newMockDir(t, map[string]string{
+		"users.GET.json":  `{"headers": {"X-File": "get"}, "body": []}`,
+		"users.HEAD.json": `{"headers": {"X-File": "head"}}`,
+		"orders.GET.json": `{"headers": {"X-File": "get"}, "body": []}`,
+		"items.POST.json": `{"status": 201}`,
+		"items.PUT.json":  `{"status": 200}`,
+	})
+	tests := []struct {
+		method, target, file string
+		status               int
+	}{
+		{"GET", "/users", "get", 200},
+		{"HEAD", "/users", "head", 204},
+		{"HEAD", "/orders", "get", 200},
+		{"GET", "/items", "", 405},
+	}
+	for _, tt := range tests {
+		rec := serve(t, newRequest(tt.method, tt.target, ""))
+		if rec.Code != tt.status || rec.Header().Get("X-File") != tt.file {
+			t.Errorf("%s %s = %d from %q, want %d from %q", tt.method, tt.target, rec.Code, rec.Header().Get("X-File"), tt.status, tt.file)
+		}
+	}
+	rec := serve(t, newRequest("GET", "/items", ""))
+	if !strings.Contains(rec.Body.String(), `"POST, PUT"`) {
+		t.Errorf("405 body = %s, want allow POST, PUT", rec.Body.String())
+	}
+}
+
+func TestLegacyRawFallback(t *testing.T) {
+	newMockDir(t, map[string]string{
+		"broken.json": `{"body": {"id": 1}`,
+		"typed.json":  `{"status": "201", "body": {"id": 1}}`,
+		"simple.json": `[{"id": 1, "name": "Simple Taro"}]`,
+	})
+	tests := []struct {
+		fallback bool
+		target   string
+		status   int
+	}{
+		{true, "/broken", 200},
+		{true, "/typed", 200},
+		{true, "/simple", 200},
+		{false, "/broken", 500},
+		{false, "/typed", 500},
+		{false, "/simple", 200},
+	}
+	for _, tt := range tests {
+		setConfig(t, &configLegacyRawFallback, tt.fallback)
+		rec := serve(t, newRequest("GET", tt.target, ""))
+		if rec.Code != tt.status {
+			t.Errorf("legacyRawFallback=%v: GET %s = %d, want %d", tt.fallback, tt.target, rec.Code, tt.status)
+		}
+		if tt.status == 200 && !strings.Contains(rec.Body.String(), `"id": 1`) {
+			t.Errorf("legacyRawFallback=%v: GET %s = %s, want the file as it is", tt.fallback, tt.target, rec.Body.String())
+		}
+	}
+}
+
+func TestEmbeddedWildcards(t *testing.T) {
+	newMockDir(t, map[string]string{
+		"user_profile.json": `{"body": {"file": "user_profile"}}`,
+		"v1/users-_.json":   `{"body": {"id": "{path.0}"}}`,
+		"_-to-_.json":       `{"body": ["{path.0}", "{path.1}"]}`,
+	})
+	tests := []struct {
+		embedded     bool
+		target, want string
+	}{
+		{false, "/user_profile", `{"file": "user_profile"}`},
+		{false, "/user-x-profile", ""},
+		{false, "/v1/users-42", ""},
+		{false, "/a-to-b", ""},
+		{true, "/user_profile", `{"file": "user_profile"}`},
+		{true, "/user-x-profile", `{"file": "user_profile"}`},
+		{true, "/v1/users-42", `{"id": "42"}`},
+		{true, "/a-to-b-to-c", `["a", "b-to-c"]`},
+	}
+	for _, tt := range tests {
+		setConfig(t, &configEmbeddedWildcards, tt.embedded)
+		rec := serve(t, newRequest("GET", tt.target, ""))
+		got := strings.TrimSpace(rec.Body.String())
+		if tt.want == "" && rec.Code != 404 || tt.want != "" && got != tt.want {
+			t.Errorf("embeddedWildcards=%v: GET %s = %d %s, want %q", tt.embedded, tt.target, rec.Code, got, tt.want)
+		}
+	}
+}
+
+func TestCaseInsensitivePaths(t *testing.T) {
+	newMockDir(t, map[string]string{
+		"Users/_/Orders.json": `{"body": {"user": "{path.0}"}}`,
+		"admin/Settings.json": `{"body": {"file": "settings"}}`,
+		"v1/Items-_.json":     `{"body": {"id": "{path.0}"}}`,
+		"files/_:[A-Z]+.json": `{"body": {"code": "{path.0}"}}`,
+	})
+	setConfig(t, &configEmbeddedWildcards, true)
+
+	tests := []struct {
+		insensitive  bool
+		target, want string
+	}{
+		{false, "/Users/AbC/Orders", `{"user": "AbC"}`},
+		{false, "/users/AbC/orders", ""},
+		{false, "/ADMIN/settings", ""},
+		{true, "/users/AbC/orders", `{"user": "AbC"}`},
+		{true, "/USERS/AbC/ORDERS", `{"user": "AbC"}`},
+		{true, "/ADMIN/settings", `{"file": "settings"}`},
+		{true, "/V1/items-X1", `{"id": "X1"}`},
+		{true, "/Files/abc", `{"code": "abc"}`},
+	}
+	for _, tt := range tests {
+		setConfig(t, &configCaseInsensitive, tt.insensitive)
+		rec := serve(t, newRequest("GET", tt.target, ""))
+		got := strings.TrimSpace(rec.Body.String())
+		if tt.want == "" && rec.Code != 404 || tt.want != "" && got != tt.want {
+			t.Errorf("caseInsensitivePaths=%v: GET %s = %d %s, want %q", tt.insensitive, tt.target, rec.Code, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+)
+
+// The request body as parsed by the matchers, so a file with many
+// entries or variants parses it only once per request
+type parsedBody struct {
+	jsonParsed bool
+	json       interface{}
+	jsonOK     bool
+
+	formParsed bool
+	form       *requestForm
+	formErr    error
+}
+
+type parsedBodyKey struct{}
+
+func withParsedBody(r *http.Request) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), parsedBodyKey{}, &parsedBody{}))
+}
+
+// Parsed body of the request (not kept if the request has no cache)
+func requestParsedBody(r *http.Request) *parsedBody {
+	if p, ok := r.Context().Value(parsedBodyKey{}).(*parsedBody); ok {
+		return p
+	}
+	return &parsedBody{}
+}
+
+// The request body decoded as JSON (false if it cannot be read or is not JSON)
+func requestJSON(r *http.Request) (interface{}, bool) {
+	p := requestParsedBody(r)
+	if !p.jsonParsed {
+		p.jsonParsed = true
+		data, err := readBody(r)
+		p.jsonOK = err == nil && json.Unmarshal(data, &p.json) == nil
+	}
+	return p.json, p.jsonOK
+}
+
+// Whether the JSON request body contains everything declared in want:
+// objects may have extra keys, arrays and other values must be equal
+func bodyMatches(want interface{}, r *http.Request) bool {
+	got, ok := requestJSON(r)
+	return ok && subsetMatches(want, got)
+}
+
+func subsetMatches(want, got interface{}) bool {
+	switch want := want.(type) {
+	case map[string]interface{}:
+		obj, ok := got.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for k, v := range want {
+			if gv, ok := obj[k]; !ok || !subsetMatches(v, gv) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		list, ok := got.([]interface{})
+		if !ok || len(list) != len(want) {
+			return false
+		}
+		for i := range want {
+			if !subsetMatches(want[i], list[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(want, got)
+}
+
+// Parse a mock file holding an array of entries, e.g.
+// [{"matchBody": {"type": "a"}, "body": ...}, {"body": ...}].
+// Plain arrays (simple mode bodies) are not entries: every element must be
+// an object of mock fields only, and at least one must have matchBody.
+func parseMockEntries(data []byte) ([]MockResponse, bool) {
+	var elems []map[string]json.RawMessage
+	if json.Unmarshal(data, &elems) != nil || len(elems) == 0 {
+		return nil, false
+	}
+	hasMatcher := false
+	for _, e := range elems {
+		if e == nil {
+			return nil, false
+		}
+		for k := range e {
+			if !mockFields[k] {
+				return nil, false
+			}
+		}
+		// "matchBody": null is the same as no matchBody
+		if v, ok := e["matchBody"]; ok && string(bytes.TrimSpace(v)) != "null" {
+			hasMatcher = true
+		}
+	}
+	if !hasMatcher {
+		return nil, false
+	}
+	var entries []MockResponse
+	if json.Unmarshal(data, &entries) != nil {
+		return nil, false
+	}
+	return entries, true
+}
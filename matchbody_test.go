@@ -0,0 +1,61 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestMatchBodyEntries(t *testing.T) {
+	newMockDir(t, map[string]string{
+		"orders.json": `[
+			{"method": ["POST"], "matchBody": {"shipping": "express"}, "body": {"entry": "express"}},
+			{"method": ["PUT"], "matchBody": {"shipping": "express"}, "body": {"entry": "put"}},
+			{"matchBody": null, "body": {"entry": "default"}}
+		]`,
+		// Only null matchers: a simple mode body, not entries
+		"plain.json": `[{"matchBody": null, "body": 1}]`,
+	})
+
+	tests := []struct {
+		method, target, body, want string
+	}{
+		{"POST", "/orders", `{"shipping": "express", "items": []}`, `{"entry": "express"}`},
+		{"PUT", "/orders", `{"shipping": "express"}`, `{"entry": "put"}`},
+		{"POST", "/orders", `{"shipping": "standard"}`, `{"entry": "default"}`},
+		{"POST", "/orders", `not json`, `{"entry": "default"}`},
+		{"GET", "/plain", "", `[{"matchBody": null, "body": 1}]`},
+	}
+	for _, tt := range tests {
+		rec := serve(t, newRequest(tt.method, tt.target, tt.body))
+		if got := strings.TrimSpace(rec.Body.String()); got != tt.want {
+			t.Errorf("%s %s %s = %s, want %s", tt.method, tt.target, tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestVariantMethodIsNotAMatcher(t *testing.T) {
+	newMockDir(t, map[string]string{
+		"items.json": `{
+			"method": ["GET", "POST"],
+			"body": {"from": "default"},
+			"variants": [{"method": ["POST"], "query": {"v": "1"}, "body": {"from": "variant"}}]
+		}`,
+	})
+	rec := serve(t, newRequest("GET", "/items?v=1", ""))
+	if got := strings.TrimSpace(rec.Body.String()); got != `{"from": "variant"}` {
+		t.Errorf("GET /items?v=1 = %s, want the variant", got)
+	}
+}
+
+func TestRequestJSONParsedOnce(t *testing.T) {
+	r := withParsedBody(newRequest("POST", "/orders", `{"a": 1}`))
+	if _, ok := requestJSON(r); !ok {
+		t.Fatal("requestJSON: body not parsed")
+	}
+	r.Body = io.NopCloser(strings.NewReader(`{"a": 2}`))
+	got, _ := requestJSON(r)
+	if !subsetMatches(map[string]interface{}{"a": 1.0}, got) {
+		t.Errorf("requestJSON parsed the body again: %v", got)
+	}
+}
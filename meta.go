@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// Metadata injected into JSON object bodies with --inject-meta
+type responseMeta struct {
+	ProcessingMs float64 `json:"processingMs"` // Time since the request arrived, delays included
+	Route        string  `json:"route"`        // Matched mock file
+}
+
+// Add configMetaKey to a JSON object body. Other bodies (arrays, scalars,
+// non-JSON) are returned unchanged. The key is appended so the order of
+// the existing keys is kept.
+func addResponseMeta(body string, start time.Time, route string) string {
+	trimmed := strings.TrimSpace(body)
+	if !strings.HasPrefix(trimmed, "{") || !json.Valid([]byte(trimmed)) {
+		return body
+	}
+
+	meta := responseMeta{Route: route}
+	if !configDeterministic {
+		meta.ProcessingMs = float64(time.Since(start).Microseconds()) / 1000
+	}
+	key, _ := json.Marshal(configMetaKey)
+	metaJSON, _ := json.Marshal(meta)
+
+	var buf bytes.Buffer
+	inner := strings.TrimSpace(trimmed[1 : len(trimmed)-1])
+	buf.WriteString(trimmed[:len(trimmed)-1])
+	if inner != "" {
+		buf.WriteByte(',')
+	}
+	buf.Write(key)
+	buf.WriteByte(':')
+	buf.Write(metaJSON)
+	buf.WriteByte('}')
+	// Keep trailing whitespace such as a newline
+	buf.WriteString(body[len(strings.TrimRight(body, " \t\r\n")):])
+	return buf.String()
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"strings"
+)
+
+// One representation of a mock, keyed by media type in "representations"
+type Representation struct {
+	MediaType string            `json:"-"`
+	Headers   map[string]string `json:"headers"`
+	Body      json.RawMessage   `json:"body"`
+	RawBody   *string           `json:"rawBody"`
+	BodyFile  string            `json:"bodyFile"`
+	BodyRef   string            `json:"bodyRef"`
+}
+
+// Representations in the order they are declared (the first one is the
+// default)
+type Representations []Representation
+
+func (rs *Representations) UnmarshalJSON(data []byte) error {
+	dec := json.NewDecoder(strings.NewReader(string(data)))
+	if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+		return fmt.Errorf("representations must be an object of media type to response")
+	}
+	*rs = nil
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		mediaType := t.(string)
+		if _, _, err := mime.ParseMediaType(mediaType); err != nil || !strings.Contains(mediaType, "/") {
+			return fmt.Errorf("invalid media type in representations: %s", mediaType)
+		}
+		rep := Representation{MediaType: mediaType}
+		if err := dec.Decode(&rep); err != nil {
+			return fmt.Errorf("representation %s: %v", mediaType, err)
+		}
+		*rs = append(*rs, rep)
+	}
+	return nil
+}
+
+// The mock with the body and headers of a representation; Content-Type
+// is the media type (application/json as usual) unless its headers set one
+func (rep Representation) apply(mock MockResponse) MockResponse {
+	headers := map[string]string{}
+	for k, v := range mock.Headers {
+		headers[k] = v
+	}
+	headers["Content-Type"] = rep.MediaType
+	if rep.MediaType == "application/json" {
+		headers["Content-Type"] = jsonContentType()
+	}
+	for k, v := range rep.Headers {
+		headers[k] = v
+	}
+	mock.Headers = headers
+	mock.Body, mock.RawBody, mock.BodyFile, mock.BodyRef = rep.Body, rep.RawBody, rep.BodyFile, rep.BodyRef
+	return mock
+}
+
+// A media range of an Accept header
+type acceptRange struct {
+	typ, subtype string
+	q            float64
+}
+
+func parseAccept(header string) []acceptRange {
+	var ranges []acceptRange
+	for _, part := range strings.Split(header, ",") {
+		mediaRange, params, _ := strings.Cut(part, ";")
+		typ, subtype, ok := strings.Cut(strings.ToLower(strings.TrimSpace(mediaRange)), "/")
+		if !ok {
+			continue
+		}
+		ranges = append(ranges, acceptRange{typ, subtype, qValue(params)})
+	}
+	return ranges
+}
+
+// Quality of a media type for the Accept ranges: that of the most
+// specific range matching it (type/subtype, then type/*, then */*)
+func acceptQuality(ranges []acceptRange, mediaType string) float64 {
+	base, _, _ := strings.Cut(mediaType, ";")
+	typ, subtype, _ := strings.Cut(strings.ToLower(strings.TrimSpace(base)), "/")
+	q, specificity := 0.0, -1
+	for _, ar := range ranges {
+		s := -1
+		switch {
+		case ar.typ == typ && ar.subtype == subtype:
+			s = 2
+		case ar.typ == typ && ar.subtype == "*":
+			s = 1
+		case ar.typ == "*" && ar.subtype == "*":
+			s = 0
+		}
+		if s > specificity {
+			q, specificity = ar.q, s
+		}
+	}
+	return q
+}
+
+// Pick the representation the Accept header prefers: the highest quality,
+// the earliest declared among equals, the first one without Accept.
+// Returns false if the header rules out all of them.
+func selectRepresentation(reps Representations, accept string) (Representation, bool) {
+	ranges := parseAccept(accept)
+	if len(ranges) == 0 {
+		return reps[0], true
+	}
+	best, bestQ := -1, 0.0
+	for i, rep := range reps {
+		if q := acceptQuality(ranges, rep.MediaType); q > bestQ {
+			best, bestQ = i, q
+		}
+	}
+	if best < 0 {
+		return Representation{}, false
+	}
+	return reps[best], true
+}
+
+// Media types of the representations, for a 406 response
+func (rs Representations) mediaTypes() []string {
+	types := make([]string, len(rs))
+	for i, rep := range rs {
+		types[i] = rep.MediaType
+	}
+	return types
+}
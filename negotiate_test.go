@@ -0,0 +1,75 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestContentNegotiation(t *testing.T) {
+	newMockDir(t, map[string]string{
+		"users/_.json": `{"representations": {
+			"application/json": {"body": {"id": "{path.0}", "name": "Alice"}},
+			"application/xml": {"rawBody": "<user><name>Alice</name></user>"},
+			"text/csv": {"rawBody": "id,name\n1,Alice\n"}
+		}}`,
+	})
+
+	tests := []struct {
+		accept      string
+		contentType string
+		body        string
+	}{
+		{"", "application/json", `"id": "1"`},
+		{"application/xml", "application/xml", "<user><name>Alice</name></user>"},
+		{"application/xml;q=0.5, application/json", "application/json", `"id": "1"`},
+		{"text/*", "text/csv", "id,name"},
+		{"*/*;q=0.1, text/csv;q=0", "application/json", `"id": "1"`},
+		{"application/xml, application/json", "application/json", `"id": "1"`}, // First declared among equals
+	}
+	for _, tt := range tests {
+		rec := serve(t, newRequest("GET", "/users/1", "", "Accept", tt.accept))
+		if rec.Code != 200 || !strings.HasPrefix(rec.Header().Get("Content-Type"), tt.contentType) || !strings.Contains(rec.Body.String(), tt.body) {
+			t.Errorf("Accept %q = %d %s %q, want %s %q", tt.accept, rec.Code, rec.Header().Get("Content-Type"), rec.Body.String(), tt.contentType, tt.body)
+		}
+		if rec.Header().Get("Vary") != "Accept" {
+			t.Errorf("Accept %q: Vary = %q, want Accept", tt.accept, rec.Header().Get("Vary"))
+		}
+	}
+}
+
+func TestContentNegotiationNotAcceptable(t *testing.T) {
+	newMockDir(t, map[string]string{
+		"users/_.json": `{"representations": {
+			"application/json": {"body": {"name": "Alice"}},
+			"application/xml": {"rawBody": "<user/>"}
+		}}`,
+	})
+
+	for _, accept := range []string{"image/png", "application/json;q=0, application/xml;q=0"} {
+		rec := serve(t, newRequest("GET", "/users/1", "", "Accept", accept))
+		if rec.Code != 406 {
+			t.Errorf("Accept %q: status %d, want 406", accept, rec.Code)
+		}
+		if !strings.Contains(rec.Body.String(), `"available":["application/json","application/xml"]`) {
+			t.Errorf("Accept %q: body %q, want the available media types", accept, rec.Body.String())
+		}
+	}
+}
+
+func TestContentNegotiationErrorFile(t *testing.T) {
+	newMockDir(t, map[string]string{
+		"users/_.json": `{"representations": {"application/json": {"body": {"name": "Alice"}}}}`,
+	})
+	errorFile := filepath.Join(t.TempDir(), "406.json")
+	if err := os.WriteFile(errorFile, []byte(`{"message": "{error} for {path}"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	setConfig(t, &configErrorFiles, map[string]string{"406": errorFile})
+
+	rec := serve(t, newRequest("GET", "/users/1", "", "Accept", "image/png"))
+	if rec.Code != 406 || rec.Body.String() != `{"message": "Not Acceptable for /users/1"}` {
+		t.Errorf("GET /users/1 = %d %q, want the 406 error file", rec.Code, rec.Body.String())
+	}
+}
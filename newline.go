@@ -0,0 +1,42 @@
+package main
+
+import (
+	"strings"
+)
+
+// Apply configTrailingNewline ("add" or "strip"; anything else keeps the
+// body as is) to a text or JSON body
+func adjustTrailingNewline(body, contentType string) string {
+	if body == "" || !isTextContentType(contentType) {
+		return body
+	}
+	switch configTrailingNewline {
+	case "add":
+		if !strings.HasSuffix(body, "\n") {
+			return body + "\n"
+		}
+	case "strip":
+		// A \r only belongs to the newline when a \n follows it
+		if strings.HasSuffix(body, "\r\n") {
+			return strings.TrimSuffix(body, "\r\n")
+		}
+		return strings.TrimSuffix(body, "\n")
+	}
+	return body
+}
+
+// JSON (the default when no Content-Type is set), XML and text/*
+func isTextContentType(ct string) bool {
+	ct = strings.ToLower(ct)
+	return ct == "" || strings.Contains(ct, "json") || strings.Contains(ct, "xml") || strings.HasPrefix(ct, "text/")
+}
+
+// Value of a header in a mock's headers, ignoring the case of the name
+func headerValue(headers map[string]string, name string) string {
+	for k, v := range headers {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return ""
+}
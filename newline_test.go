@@ -0,0 +1,28 @@
+package main
+
+import "testing"
+
+func TestAdjustTrailingNewline(t *testing.T) {
+	tests := []struct {
+		mode, body, contentType, want string
+	}{
+		{"strip", "a\n", "", "a"},
+		{"strip", "a\r\n", "", "a"},
+		{"strip", "a\r", "", "a\r"},
+		{"strip", "a\n\n", "", "a\n"},
+		{"strip", "a\r\r\n", "text/plain", "a\r"},
+		{"strip", "a", "", "a"},
+		{"strip", "a\n", "application/octet-stream", "a\n"},
+		{"add", "a", "application/json", "a\n"},
+		{"add", "a\n", "", "a\n"},
+		{"add", "a\r", "", "a\r\n"},
+		{"add", "", "", ""},
+		{"", "a\n", "", "a\n"},
+	}
+	for _, tt := range tests {
+		setConfig(t, &configTrailingNewline, tt.mode)
+		if got := adjustTrailingNewline(tt.body, tt.contentType); got != tt.want {
+			t.Errorf("%s %q (%s) = %q, want %q", tt.mode, tt.body, tt.contentType, got, tt.want)
+		}
+	}
+}
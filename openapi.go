@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Skeleton written by --from-openapi (field order matches the README)
+type importedMock struct {
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    interface{}       `json:"body,omitempty"`
+	RawBody string            `json:"rawBody,omitempty"`
+}
+
+var openAPIMethods = []string{"get", "head", "post", "put", "patch", "delete", "options"}
+
+// A path parameter such as {id}, also inside a segment ({name}.json)
+var openAPIParamRe = regexp.MustCompile(`\{[^{}/]+\}`)
+
+// apimock --from-openapi <spec> [--dir mock]: write one mock file per
+// path and method of an OpenAPI 3 (or Swagger 2) document and exit.
+// Existing files are kept.
+func runImportOpenAPI(specPath string) {
+	loadConfigFiles()
+	if *mockDir != "" {
+		configDir = expandHome(*mockDir)
+	}
+
+	data, err := os.ReadFile(specPath)
+	if err == nil {
+		data, err = yamlToJSON(data)
+	}
+	var spec map[string]interface{}
+	if err == nil {
+		err = json.Unmarshal(data, &spec)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read %s: %v\n", specPath, err)
+		os.Exit(1)
+	}
+	paths, _ := spec["paths"].(map[string]interface{})
+	if len(paths) == 0 {
+		fmt.Fprintf(os.Stderr, "No paths found in %s\n", specPath)
+		os.Exit(1)
+	}
+
+	g := &schemaGenerator{root: spec}
+	created, skipped := 0, 0
+	for _, route := range sortedKeys(paths) {
+		item, _ := paths[route].(map[string]interface{})
+		relPath := openAPIRouteToFile(route)
+		if relPath == "" {
+			log.Printf("[WARNING] Skipping %s: the path would be outside the mock directory", route)
+			continue
+		}
+		for _, method := range openAPIMethods {
+			op, ok := item[method].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			filePath := filepath.Join(configDir, relPath+"."+strings.ToUpper(method)+".json")
+			if _, err := os.Stat(filePath); err == nil {
+				fmt.Printf("Skipped %s (already exists)\n", filePath)
+				skipped++
+				continue
+			}
+
+			out, _ := json.MarshalIndent(g.importOperation(op), "", "  ")
+			if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to create directory: %v\n", err)
+				os.Exit(1)
+			}
+			if err := os.WriteFile(filePath, append(out, '\n'), 0644); err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to write file: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("Created %s\n", filePath)
+			created++
+		}
+	}
+	fmt.Printf("%d file(s) created, %d skipped\n", created, skipped)
+}
+
+// Mock file path (without extension) for an OpenAPI path: parameters
+// become the _ wildcard and the root path is index ("" for a path that
+// would leave the mock directory, e.g. /../x)
+func openAPIRouteToFile(route string) string {
+	var parts []string
+	for _, seg := range strings.Split(strings.Trim(route, "/"), "/") {
+		if seg != "" {
+			parts = append(parts, openAPIParamRe.ReplaceAllString(seg, "_"))
+		}
+	}
+	if len(parts) == 0 {
+		return "index"
+	}
+	relPath := filepath.Join(parts...)
+	if !filepath.IsLocal(relPath) {
+		return ""
+	}
+	return relPath
+}
+
+// Mock for an operation: its first success response (the lowest 2xx
+// status, else the lowest declared one) with the first example, or a
+// sample generated from the schema
+func (g *schemaGenerator) importOperation(op map[string]interface{}) importedMock {
+	responses, _ := op["responses"].(map[string]interface{})
+	code, status := "", 0
+	for _, c := range sortedKeys(responses) {
+		n, err := strconv.Atoi(c)
+		if c == "default" {
+			n, err = 200, nil
+		}
+		if err != nil {
+			continue
+		}
+		if status == 0 || (n/100 == 2 && status/100 != 2) {
+			code, status = c, n
+		}
+	}
+	if status == 0 {
+		return importedMock{Status: 200}
+	}
+
+	mock := importedMock{Status: status}
+	res := g.resolve(asObject(responses[code]))
+	if status == 204 || status == 304 {
+		return mock
+	}
+
+	// OpenAPI 3: content by media type; Swagger 2: examples and schema
+	contentType, media := "", map[string]interface{}{}
+	if content := asObject(res["content"]); len(content) > 0 {
+		contentType = firstMediaType(content)
+		media = asObject(content[contentType])
+	} else {
+		media["schema"] = res["schema"]
+		if examples := asObject(res["examples"]); len(examples) > 0 {
+			contentType = firstMediaType(examples)
+			media["example"] = examples[contentType]
+		}
+	}
+
+	body, ok := media["example"]
+	if !ok {
+		for _, name := range sortedKeys(asObject(media["examples"])) {
+			if ex := g.resolve(asObject(asObject(media["examples"])[name])); ex["value"] != nil {
+				body, ok = ex["value"], true
+				break
+			}
+		}
+	}
+	if !ok {
+		if schema := asObject(media["schema"]); len(schema) > 0 {
+			body, ok = g.generate(schema, 0), true
+		}
+	}
+	if !ok {
+		return mock
+	}
+
+	if contentType != "" && !strings.Contains(contentType, "json") {
+		mock.Headers = map[string]string{"Content-Type": contentType}
+		if s, isString := body.(string); isString {
+			mock.RawBody = s
+			return mock
+		}
+	}
+	mock.Body = body
+	return mock
+}
+
+// JSON media type first, else the first in sorted order
+func firstMediaType(m map[string]interface{}) string {
+	keys := sortedKeys(m)
+	for _, k := range keys {
+		if strings.Contains(k, "json") {
+			return k
+		}
+	}
+	return keys[0]
+}
+
+func asObject(v interface{}) map[string]interface{} {
+	m, _ := v.(map[string]interface{})
+	return m
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOpenAPIRouteToFile(t *testing.T) {
+	tests := []struct{ route, want string }{
+		{"/", "index"},
+		{"/pets", "pets"},
+		{"/pets/{petId}/", filepath.Join("pets", "_")},
+		{"/files/{name}.{ext}", filepath.Join("files", "_._")},
+		{"/a/../b", "b"},
+		{"/../../etc/x", ""},
+		{"/a/../../b", ""},
+		{"/..", ""},
+	}
+	for _, tt := range tests {
+		if got := openAPIRouteToFile(tt.route); got != tt.want {
+			t.Errorf("openAPIRouteToFile(%q) = %q, want %q", tt.route, got, tt.want)
+		}
+	}
+}
+
+func TestImportOpenAPI(t *testing.T) {
+	dir := newMockDir(t, nil)
+	spec := filepath.Join(t.TempDir(), "openapi.yaml")
+	os.WriteFile(spec, []byte(`openapi: 3.0.0
+paths:
+  /:
+    get:
+      responses:
+        "200":
+          content:
+            application/json:
+              example: {root: true}
+  /pets/{petId}:
+    get:
+      responses:
+        "200":
+          content:
+            application/json:
+              example: {id: 1}
+  /../../outside:
+    get:
+      responses:
+        "200": {}
+`), 0644)
+	setConfig(t, mockDir, dir)
+	runImportOpenAPI(spec)
+
+	for _, name := range []string{"index.GET.json", filepath.Join("pets", "_.GET.json")} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("%s not created: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "..", "..", "outside.GET.json")); err == nil {
+		t.Error("a file was written outside the mock directory")
+	}
+
+	rec := serve(t, newRequest("GET", "/", ""))
+	if got := strings.Join(strings.Fields(rec.Body.String()), ""); rec.Code != 200 || got != `{"root":true}` {
+		t.Errorf("GET / = %d %s, want the imported root mock", rec.Code, rec.Body.String())
+	}
+}
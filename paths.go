@@ -0,0 +1,43 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Mock path of a request path: without the leading and trailing slash and
+// with repeated slashes collapsed, so /users/, /users and //users all
+// match users.json (or users/index.json)
+func normalizeRequestPath(p string) string {
+	return strings.Trim(collapseSlashes(p), "/")
+}
+
+func collapseSlashes(p string) string {
+	for strings.Contains(p, "//") {
+		p = strings.ReplaceAll(p, "//", "/")
+	}
+	return p
+}
+
+// Collapse repeated slashes before routing, which would otherwise be
+// answered with a redirect by http.ServeMux
+func withCleanSlashes(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "//") {
+			u := *r.URL
+			u.Path = collapseSlashes(u.Path)
+			u.RawPath = collapseSlashes(u.RawPath)
+			r2 := r.Clone(r.Context())
+			r2.URL = &u
+			r = r2
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Whether / is served by a mock (index.json in the mock directory) instead
+// of the static files, the listing or the status message
+func hasRootMock(r *http.Request) bool {
+	filePath, _ := findBestMockFile(requestBaseDir(r), "", r.Method, r.URL.Query())
+	return filePath != ""
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTrailingAndRepeatedSlashes(t *testing.T) {
+	newMockDir(t, map[string]string{
+		"users/index.json":   `{"body": {"file": "users/index"}}`,
+		"users/_/posts.json": `{"body": {"user": "{path.0}"}}`,
+		"orders.json":        `{"body": {"file": "orders"}}`,
+	})
+	handler := withCleanSlashes(http.HandlerFunc(mockHandler))
+
+	tests := []struct{ target, want string }{
+		{"/users", `{"file": "users/index"}`},
+		{"/users/", `{"file": "users/index"}`},
+		{"/orders", `{"file": "orders"}`},
+		{"/orders/", `{"file": "orders"}`},
+		{"/users/42/posts", `{"user": "42"}`},
+		{"/users//42/posts/", `{"user": "42"}`},
+		{"//users///42//posts", `{"user": "42"}`},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, newRequest("GET", tt.target, ""))
+		if got := strings.TrimSpace(rec.Body.String()); rec.Code != 200 || got != tt.want {
+			t.Errorf("GET %s = %d %s, want %s", tt.target, rec.Code, got, tt.want)
+		}
+	}
+}
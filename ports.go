@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A port to listen on and the subdirectory of configDir it serves
+// ("" for the whole mock directory)
+type listenPort struct {
+	Port string
+	Dir  string
+}
+
+func (p listenPort) String() string {
+	if p.Dir == "" {
+		return p.Port
+	}
+	return p.Port + "=" + p.Dir
+}
+
+// Parse "8080" or "8081=orders"
+func parseListenPort(s string) (listenPort, error) {
+	port, dir, _ := strings.Cut(s, "=")
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return listenPort{}, fmt.Errorf("invalid port '%s'", port)
+	}
+	if dir != "" {
+		dir = filepath.Clean(filepath.FromSlash(dir))
+	}
+	return listenPort{Port: port, Dir: dir}, nil
+}
+
+// Ports of the port setting in .apimockrc: 8080, "8080", [8080, 8081]
+// or {"8081": "orders", "8082": "billing"} (port to subdirectory)
+func parsePorts(v interface{}) ([]listenPort, error) {
+	switch v := v.(type) {
+	case string:
+		p, err := parseListenPort(v)
+		return []listenPort{p}, err
+	case float64:
+		if v != math.Trunc(v) || v < 1 || v > 65535 {
+			return nil, fmt.Errorf("invalid port %v", v)
+		}
+		return []listenPort{{Port: strconv.Itoa(int(v))}}, nil
+	case []interface{}:
+		var ports []listenPort
+		for _, e := range v {
+			p, err := parsePorts(e)
+			if err != nil {
+				return nil, err
+			}
+			ports = append(ports, p...)
+		}
+		return ports, nil
+	case map[string]interface{}:
+		var ports []listenPort
+		for port, dir := range v {
+			d, ok := dir.(string)
+			if !ok {
+				return nil, fmt.Errorf("directory of port %s must be a string", port)
+			}
+			p, err := parseListenPort(port + "=" + d)
+			if err != nil {
+				return nil, err
+			}
+			ports = append(ports, p)
+		}
+		sort.Slice(ports, func(i, j int) bool {
+			a, _ := strconv.Atoi(ports[i].Port)
+			b, _ := strconv.Atoi(ports[j].Port)
+			return a < b
+		})
+		return ports, nil
+	}
+	return nil, fmt.Errorf("port must be a number, a string, an array or an object")
+}
+
+func formatPorts(ports []listenPort) string {
+	s := make([]string, len(ports))
+	for i, p := range ports {
+		s[i] = p.String()
+	}
+	return strings.Join(s, ", ")
+}
+
+// Repeatable --port flag
+type portFlag []listenPort
+
+func newPortFlag(name, usage string) *portFlag {
+	p := &portFlag{}
+	flag.Var(p, name, usage)
+	return p
+}
+
+func (p *portFlag) String() string {
+	return formatPorts(*p)
+}
+
+func (p *portFlag) Set(s string) error {
+	port, err := parseListenPort(s)
+	if err != nil {
+		return err
+	}
+	*p = append(*p, port)
+	return nil
+}
+
+type portDirKey struct{}
+
+// Serve the requests of a port from a subdirectory of configDir
+func withPortDir(dir string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), portDirKey{}, dir)))
+	})
+}
+
+// Mock directory of the port a request came in on
+func portDir(r *http.Request) string {
+	if dir, ok := r.Context().Value(portDirKey{}).(string); ok {
+		return dir
+	}
+	return configDir
+}
+
+// Mock directory of a request: that of its port, or of its Host header
+// with --host-routing
+func requestBaseDir(r *http.Request) string {
+	dir := portDir(r)
+	if configHostRouting {
+		dir = hostDir(dir, r.Host)
+	}
+	return dir
+}
+
+// One server per configured port, all with the same handlers (a single
+// one for the whole mock directory with a Unix socket)
+func newServers() []*http.Server {
+	if configSocket != "" {
+		return []*http.Server{{Addr: configSocket, Handler: withCleanSlashes(http.DefaultServeMux)}}
+	}
+	servers := make([]*http.Server, len(configPorts))
+	for i, p := range configPorts {
+		handler := withCleanSlashes(http.DefaultServeMux)
+		if p.Dir != "" {
+			handler = withPortDir(filepath.Join(configDir, p.Dir), handler)
+		}
+		servers[i] = &http.Server{Addr: net.JoinHostPort(configHost, p.Port), Handler: handler}
+	}
+	return servers
+}
+
+// Listen on the server's address: the Unix socket (replacing a stale
+// socket file left by a previous run) or host:port
+func listen(srv *http.Server) (net.Listener, error) {
+	if configSocket == "" {
+		return net.Listen("tcp", srv.Addr)
+	}
+	if info, err := os.Lstat(srv.Addr); err == nil && info.Mode()&os.ModeSocket != 0 {
+		os.Remove(srv.Addr)
+	}
+	return net.Listen("unix", srv.Addr)
+}
+
+// The host setting must be an IP address or a name that resolves
+func validateListenHost(host string) error {
+	if host == "" || net.ParseIP(host) != nil {
+		return nil
+	}
+	_, err := net.LookupHost(host)
+	return err
+}
+
+// URL clients reach a port at (localhost when listening on all interfaces)
+func serverURL(port string) string {
+	host := configHost
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "localhost"
+	}
+	return serverScheme() + "://" + net.JoinHostPort(host, port)
+}
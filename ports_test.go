@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParsePorts(t *testing.T) {
+	tests := []struct {
+		json string
+		want string // formatPorts of the result, "" for an error
+	}{
+		{`8080`, "8080"},
+		{`"8081=orders"`, "8081=orders"},
+		{`[8080, "8081"]`, "8080, 8081"},
+		{`{"8080": "a", "10000": "b", "9000": "c"}`, "8080=a, 9000=c, 10000=b"},
+		{`0`, ""},
+		{`70000`, ""},
+		{`80.5`, ""},
+		{`-1`, ""},
+		{`"0"`, ""},
+		{`"65536"`, ""},
+		{`[8080, 0]`, ""},
+		{`{"0": "a"}`, ""},
+		{`true`, ""},
+	}
+	for _, tt := range tests {
+		var v interface{}
+		if err := json.Unmarshal([]byte(tt.json), &v); err != nil {
+			t.Fatal(err)
+		}
+		ports, err := parsePorts(v)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("parsePorts(%s) = %s, want an error", tt.json, formatPorts(ports))
+			}
+			continue
+		}
+		if err != nil || formatPorts(ports) != tt.want {
+			t.Errorf("parsePorts(%s) = %s, %v, want %s", tt.json, formatPorts(ports), err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// Encode the JSON body as a Protocol Buffers message
+type ProtobufEncoding struct {
+	DescriptorSet string `json:"descriptorSet"` // FileDescriptorSet relative to the mock directory (protoc --descriptor_set_out --include_imports)
+	Message       string `json:"message"`       // Fully qualified message name, e.g. "acme.v1.User"
+}
+
+type descriptorSet struct {
+	modTime time.Time
+	files   *protoregistry.Files
+}
+
+// Parsed descriptor sets (re-read when the file changes)
+var (
+	descriptorMu    sync.Mutex
+	descriptorCache = map[string]*descriptorSet{}
+)
+
+func loadDescriptorSet(path string) (*protoregistry.Files, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	descriptorMu.Lock()
+	defer descriptorMu.Unlock()
+	if d, ok := descriptorCache[path]; ok && d.modTime.Equal(info.ModTime()) {
+		return d.files, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var fds descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(data, &fds); err != nil {
+		return nil, fmt.Errorf("%s is not a descriptor set: %v", path, err)
+	}
+	files, err := protodesc.NewFiles(&fds)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	descriptorCache[path] = &descriptorSet{modTime: info.ModTime(), files: files}
+	return files, nil
+}
+
+// Marshal a JSON body (in the protobuf JSON mapping) to the binary encoding
+func (p *ProtobufEncoding) encode(body string) (string, error) {
+	files, err := loadDescriptorSet(filepath.Join(configDir, p.DescriptorSet))
+	if err != nil {
+		return "", err
+	}
+	desc, err := files.FindDescriptorByName(protoreflect.FullName(p.Message))
+	if err != nil {
+		return "", fmt.Errorf("message %s: %v", p.Message, err)
+	}
+	md, ok := desc.(protoreflect.MessageDescriptor)
+	if !ok {
+		return "", fmt.Errorf("%s is not a message", p.Message)
+	}
+
+	msg := dynamicpb.NewMessage(md)
+	if body != "" && body != "null" {
+		if err := protojson.Unmarshal([]byte(body), msg); err != nil {
+			return "", fmt.Errorf("body does not match %s: %v", p.Message, err)
+		}
+	}
+	out, err := proto.Marshal(msg)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// Shared random source for generated data. Seeded once at startup
+// (with --seed for reproducible output).
+var (
+	rngMu sync.Mutex
+	rng   = rand.New(rand.NewPCG(uint64(time.Now().UnixNano()), 0))
+)
+
+func seedRandom(seed int64) {
+	rngMu.Lock()
+	defer rngMu.Unlock()
+	rng = rand.New(rand.NewPCG(uint64(seed), 0))
+}
+
+// Random int in [min, max]
+func randomInt(min, max int) int {
+	if max <= min {
+		return min
+	}
+	rngMu.Lock()
+	defer rngMu.Unlock()
+	return min + rng.IntN(max-min+1)
+}
+
+// Random float in [min, max)
+func randomFloat(min, max float64) float64 {
+	rngMu.Lock()
+	defer rngMu.Unlock()
+	return min + rng.Float64()*(max-min)
+}
+
+// Clock of {now} tokens, pinned with --deterministic
+var pinnedTime = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func now() time.Time {
+	if configDeterministic {
+		return pinnedTime
+	}
+	return time.Now()
+}
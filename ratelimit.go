@@ -0,0 +1,237 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type RateLimit struct {
+	Requests int `json:"requests"` // Allowed requests per window
+	Window   int `json:"window"`   // Window length in seconds (default: 60)
+
+	// "fixedWindow" (default), "slidingWindow" or "tokenBucket"
+	Algorithm       string  `json:"algorithm"`
+	Burst           int     `json:"burst"`           // Token bucket capacity (default: requests)
+	RefillPerSecond float64 `json:"refillPerSecond"` // Token refill rate (default: requests / window)
+
+	// Response once the limit is exhausted
+	Status     int               `json:"status"`     // Default: 429
+	Headers    map[string]string `json:"headers"`    // Extra headers
+	Body       json.RawMessage   `json:"body"`       // Default: {"error":"Too Many Requests"}
+	RetryAfter int               `json:"retryAfter"` // Retry-After base in seconds (default: until the window resets)
+	Jitter     int               `json:"jitter"`     // Random 0..jitter seconds added to Retry-After
+}
+
+func (rl *RateLimit) UnmarshalJSON(data []byte) error {
+	type plain RateLimit
+	if err := json.Unmarshal(data, (*plain)(rl)); err != nil {
+		return err
+	}
+	switch rl.Algorithm {
+	case "", "fixedWindow", "slidingWindow":
+		if rl.Requests <= 0 {
+			return fmt.Errorf("rateLimit needs requests of at least 1")
+		}
+	case "tokenBucket":
+		if rl.capacity() <= 0 || rl.RefillPerSecond < 0 || rl.refillRate() <= 0 {
+			return fmt.Errorf("rateLimit with tokenBucket needs requests, or burst and refillPerSecond, above 0")
+		}
+	default:
+		return fmt.Errorf("unknown rateLimit algorithm '%s' (use fixedWindow, slidingWindow or tokenBucket)", rl.Algorithm)
+	}
+	return nil
+}
+
+// Fixed window counters per route
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+var (
+	rateMu      sync.Mutex
+	rateWindows = map[string]*rateWindow{}
+)
+
+// Forget the requests counted by all rate limits
+func resetRateLimits() {
+	rateMu.Lock()
+	defer rateMu.Unlock()
+	rateWindows = map[string]*rateWindow{}
+	slidingWindows = map[string][]time.Time{}
+	tokenBuckets = map[string]*tokenBucket{}
+}
+
+// Count a request and report whether it is allowed, along with the
+// remaining requests and the time the window resets
+func takeRateLimit(route string, rl *RateLimit) (bool, int, time.Time) {
+	window := time.Duration(rl.Window) * time.Second
+	if window <= 0 {
+		window = time.Minute
+	}
+
+	rateMu.Lock()
+	defer rateMu.Unlock()
+	now := time.Now()
+	win, ok := rateWindows[route]
+	if !ok || now.Sub(win.start) >= window {
+		win = &rateWindow{start: now}
+		rateWindows[route] = win
+	}
+	reset := win.start.Add(window)
+	if win.count >= rl.Requests {
+		return false, 0, reset
+	}
+	win.count++
+	return true, rl.Requests - win.count, reset
+}
+
+// Times of the allowed requests in the last window, per route
+var slidingWindows = map[string][]time.Time{}
+
+// Like takeRateLimit, but the window ends at the current request: a
+// request is allowed if fewer than rl.Requests were allowed in the last
+// window. The reset time is when the oldest of them leaves the window.
+func takeSlidingWindow(route string, rl *RateLimit) (bool, int, time.Time) {
+	window := time.Duration(rl.Window) * time.Second
+	if window <= 0 {
+		window = time.Minute
+	}
+
+	rateMu.Lock()
+	defer rateMu.Unlock()
+	now := time.Now()
+	times := slidingWindows[route]
+	for len(times) > 0 && now.Sub(times[0]) >= window {
+		times = times[1:]
+	}
+	allowed := len(times) < rl.Requests
+	if allowed {
+		times = append(times, now)
+	}
+	slidingWindows[route] = times
+
+	reset := now
+	if len(times) > 0 {
+		reset = times[0].Add(window)
+	}
+	return allowed, rl.Requests - len(times), reset
+}
+
+// Token buckets per route
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+var tokenBuckets = map[string]*tokenBucket{}
+
+func (rl *RateLimit) capacity() int {
+	if rl.Burst > 0 {
+		return rl.Burst
+	}
+	return rl.Requests
+}
+
+func (rl *RateLimit) refillRate() float64 {
+	if rl.RefillPerSecond > 0 {
+		return rl.RefillPerSecond
+	}
+	window := rl.Window
+	if window <= 0 {
+		window = 60
+	}
+	return float64(rl.Requests) / float64(window)
+}
+
+// Take a token and report whether the request is allowed, along with the
+// remaining tokens, the time the bucket is full again and the time the
+// next token arrives
+func takeToken(route string, rl *RateLimit) (bool, int, time.Time, time.Time) {
+	capacity, rate := float64(rl.capacity()), rl.refillRate()
+
+	rateMu.Lock()
+	defer rateMu.Unlock()
+	now := time.Now()
+	b, ok := tokenBuckets[route]
+	if !ok {
+		b = &tokenBucket{tokens: capacity, last: now}
+		tokenBuckets[route] = b
+	}
+	b.tokens += now.Sub(b.last).Seconds() * rate
+	if b.tokens > capacity {
+		b.tokens = capacity
+	}
+	b.last = now
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	if rate <= 0 {
+		return allowed, int(b.tokens), now, now
+	}
+	full := now.Add(time.Duration((capacity - b.tokens) / rate * float64(time.Second)))
+	next := now
+	if b.tokens < 1 {
+		next = now.Add(time.Duration((1 - b.tokens) / rate * float64(time.Second)))
+	}
+	return allowed, int(b.tokens), full, next
+}
+
+// Apply the rate limit of a mock. Returns false if the request was
+// rejected (the response has been written). Request tokens in the
+// response are expanded with td.
+func checkRateLimit(w http.ResponseWriter, route string, rl *RateLimit, td *templateData) bool {
+	var allowed bool
+	var remaining, limit int
+	var reset, retryAt time.Time
+	switch rl.Algorithm {
+	case "tokenBucket":
+		allowed, remaining, reset, retryAt = takeToken(route, rl)
+		limit = rl.capacity()
+	case "slidingWindow":
+		allowed, remaining, reset = takeSlidingWindow(route, rl)
+		retryAt, limit = reset, rl.Requests
+	default:
+		allowed, remaining, reset = takeRateLimit(route, rl)
+		retryAt, limit = reset, rl.Requests
+	}
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(limit))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+	w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+	if allowed {
+		return true
+	}
+
+	retryAfter := rl.RetryAfter
+	if retryAfter <= 0 {
+		retryAfter = int(time.Until(retryAt).Seconds() + 0.999)
+	}
+	if rl.Jitter > 0 {
+		retryAfter += randomInt(0, rl.Jitter)
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	for k, v := range rl.Headers {
+		w.Header().Set(k, td.replaceRequestTokens(v))
+	}
+
+	status := rl.Status
+	if status == 0 {
+		status = http.StatusTooManyRequests
+	}
+	if len(rl.Body) == 0 {
+		respondJSON(w, status, map[string]string{"error": "Too Many Requests"})
+		return false
+	}
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", jsonContentType())
+	}
+	w.WriteHeader(status)
+	w.Write([]byte(expandJSONStrings(string(rl.Body), td.replaceRequestTokens)))
+	return false
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRateLimitValidation(t *testing.T) {
+	tests := []struct {
+		json string
+		ok   bool
+	}{
+		{`{"requests": 5}`, true},
+		{`{}`, false},
+		{`{"requests": 0}`, false},
+		{`{"requests": -1, "window": 10}`, false},
+		{`{"algorithm": "slidingWindow", "requests": 3}`, true},
+		{`{"algorithm": "slidingWindow"}`, false},
+		{`{"algorithm": "tokenBucket", "requests": 10}`, true},
+		{`{"algorithm": "tokenBucket", "burst": 20, "refillPerSecond": 5}`, true},
+		{`{"algorithm": "tokenBucket", "burst": 20}`, false},
+		{`{"algorithm": "tokenBucket", "refillPerSecond": 5}`, false},
+		{`{"algorithm": "tokenBucket", "requests": 10, "burst": 0, "refillPerSecond": -1}`, false},
+		{`{"algorithm": "leakyBucket", "requests": 10}`, false},
+	}
+	for _, tt := range tests {
+		var rl RateLimit
+		err := json.Unmarshal([]byte(tt.json), &rl)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: err = %v, want ok = %v", tt.json, err, tt.ok)
+		}
+	}
+}
+
+func TestFixedWindowThrottlesAfterLimit(t *testing.T) {
+	newMockDir(t, map[string]string{
+		"limited.json": `{"rateLimit": {"requests": 2, "window": 60}, "body": {"ok": true}}`,
+	})
+
+	for i := 1; i <= 3; i++ {
+		rec := serve(t, newRequest("GET", "/limited", ""))
+		want := 200
+		if i == 3 {
+			want = 429
+		}
+		if rec.Code != want {
+			t.Errorf("request %d: status %d, want %d", i, rec.Code, want)
+		}
+	}
+}
+
+func TestSlidingWindowThrottlesAfterLimit(t *testing.T) {
+	newMockDir(t, map[string]string{
+		"sliding.json": `{"rateLimit": {"algorithm": "slidingWindow", "requests": 2, "window": 60}, "body": {"ok": true}}`,
+	})
+	t.Cleanup(func() { delete(slidingWindows, "sliding.json") })
+
+	for i := 1; i <= 3; i++ {
+		rec := serve(t, newRequest("GET", "/sliding", ""))
+		want := 200
+		if i == 3 {
+			want = 429
+		}
+		if rec.Code != want {
+			t.Errorf("request %d: status %d, want %d", i, rec.Code, want)
+		}
+		if i == 3 {
+			if rec.Header().Get("X-RateLimit-Remaining") != "0" {
+				t.Errorf("X-RateLimit-Remaining = %q, want 0", rec.Header().Get("X-RateLimit-Remaining"))
+			}
+			if s, _ := strconv.Atoi(rec.Header().Get("Retry-After")); s < 1 || s > 60 {
+				t.Errorf("Retry-After = %q, want 1-60", rec.Header().Get("Retry-After"))
+			}
+		}
+	}
+
+	// Once the oldest request leaves the window, exactly one more is allowed
+	rateMu.Lock()
+	slidingWindows["sliding.json"][0] = time.Now().Add(-61 * time.Second)
+	rateMu.Unlock()
+	if rec := serve(t, newRequest("GET", "/sliding", "")); rec.Code != 200 {
+		t.Errorf("after the oldest request expired: status %d, want 200", rec.Code)
+	}
+	if rec := serve(t, newRequest("GET", "/sliding", "")); rec.Code != 429 {
+		t.Errorf("next request: status %d, want 429", rec.Code)
+	}
+}
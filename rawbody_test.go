@@ -0,0 +1,72 @@
+package main
+
+import "testing"
+
+func TestRawBody(t *testing.T) {
+	newMockDir(t, map[string]string{
+		"truncated.json": `{"rawBody": "{\"id\": 1, \"name\": \"Ta"}`,
+		"tokens.json":    `{"rawBody": "{path.0} {query.q}"}`,
+		"empty.json":     `{"status": 200, "rawBody": ""}`,
+		"text.json":      `{"headers": {"Content-Type": "text/plain"}, "rawBody": "plain"}`,
+	})
+
+	tests := []struct {
+		path, body, contentType string
+		noCharset               bool
+	}{
+		{"/truncated", `{"id": 1, "name": "Ta`, "application/json; charset=utf-8", false},
+		{"/truncated", `{"id": 1, "name": "Ta`, "application/json", true},
+		{"/tokens?q=x", "{path.0} {query.q}", "application/json; charset=utf-8", false},
+		{"/empty", "", "application/json; charset=utf-8", false},
+		{"/text", "plain", "text/plain", false},
+	}
+	for _, tt := range tests {
+		setConfig(t, &configNoCharset, tt.noCharset)
+		rec := serve(t, newRequest("GET", tt.path, ""))
+		if rec.Code != 200 {
+			t.Errorf("GET %s: status %d, want 200", tt.path, rec.Code)
+		}
+		if rec.Body.String() != tt.body {
+			t.Errorf("GET %s: body %q, want %q", tt.path, rec.Body.String(), tt.body)
+		}
+		if got := rec.Header().Get("Content-Type"); got != tt.contentType {
+			t.Errorf("GET %s: Content-Type %q, want %q", tt.path, got, tt.contentType)
+		}
+	}
+}
+
+func TestEmptyBodyStatus(t *testing.T) {
+	newMockDir(t, map[string]string{
+		"explicit.json": `{"status": 200, "headers": {"Content-Type": "text/html"}}`,
+		"created.json":  `{"status": 201}`,
+		"unset.json":    `{"headers": {"X-Empty": "1"}}`,
+		"emptyraw.json": `{"rawBody": ""}`,
+		"nullbody.json": `{"body": null}`,
+		"forced.json":   `{"forceStatus": true}`,
+	})
+
+	tests := []struct {
+		target string
+		status int
+	}{
+		{"/explicit", 200},
+		{"/created", 201},
+		{"/unset", 204},
+		{"/emptyraw", 200},
+		{"/nullbody", 204},
+	}
+	for _, tt := range tests {
+		rec := serve(t, newRequest("GET", tt.target, ""))
+		if rec.Code != tt.status || rec.Body.Len() != 0 {
+			t.Errorf("GET %s = %d %q, want %d with an empty body", tt.target, rec.Code, rec.Body.String(), tt.status)
+		}
+	}
+
+	// A status forced by the client is kept too
+	if rec := serve(t, newRequest("GET", "/forced", "", "X-Force-Status", "200")); rec.Code != 200 {
+		t.Errorf("GET /forced with X-Force-Status: 200 = %d, want 200", rec.Code)
+	}
+	if rec := serve(t, newRequest("GET", "/forced", "")); rec.Code != 204 {
+		t.Errorf("GET /forced = %d, want 204", rec.Code)
+	}
+}
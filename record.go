@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Response headers worth keeping in recorded mocks (and any X-* header)
+var recordedHeaders = []string{
+	"Content-Type", "Cache-Control", "ETag", "Last-Modified", "Location",
+	"Link", "Retry-After", "WWW-Authenticate",
+}
+
+// Path segments that look like ids: numbers, UUIDs and long hex strings
+var idSegmentRe = regexp.MustCompile(`^(\d+|[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}|[0-9a-fA-F]{24,})$`)
+
+// Save a proxied response as a mock file below baseDir
+func recordResponse(r *http.Request, resp *http.Response, baseDir string) {
+	if resp.StatusCode >= 500 {
+		log.Printf("[RECORD] %s %s: not recorded (status %d)", r.Method, r.URL.Path, resp.StatusCode)
+		return
+	}
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	if err != nil {
+		log.Printf("[WARNING] [RECORD] %s %s: %v", r.Method, r.URL.Path, err)
+		return
+	}
+
+	mock, err := recordMockFile(r, resp, data)
+	if err != nil {
+		log.Printf("[RECORD] %s %s: not recorded (%v)", r.Method, r.URL.Path, err)
+		return
+	}
+	rel, err := recordPath(baseDir, r.URL.Path)
+	if err != nil {
+		log.Printf("[RECORD] %s %s: not recorded (%v)", r.Method, r.URL.Path, err)
+		return
+	}
+	file := filepath.Join(baseDir, filepath.FromSlash(rel))
+	if _, err := os.Stat(file); err == nil && !*recordOverwrite {
+		log.Printf("[RECORD] %s %s: %s exists (use --record-overwrite to replace it)", r.Method, r.URL.Path, rel)
+		return
+	}
+	if err := writeFileAtomic(file, mock); err != nil {
+		log.Printf("[WARNING] [RECORD] %s %s: %v", r.Method, r.URL.Path, err)
+		return
+	}
+	log.Printf("[RECORD] %s %s -> %s", r.Method, r.URL.Path, rel)
+}
+
+// A recorded mock file (fields in the order of MockResponse)
+type recordedMock struct {
+	Method  []string          `json:"method"`
+	Status  int               `json:"status,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    json.RawMessage   `json:"body,omitempty"`
+	RawBody *string           `json:"rawBody,omitempty"`
+}
+
+// Mock file content for a response
+func recordMockFile(r *http.Request, resp *http.Response, data []byte) ([]byte, error) {
+	switch resp.Header.Get("Content-Encoding") {
+	case "", "identity":
+	case "gzip":
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		if data, err = io.ReadAll(zr); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported Content-Encoding %s", resp.Header.Get("Content-Encoding"))
+	}
+
+	mock := recordedMock{Method: []string{r.Method}}
+	if resp.StatusCode != http.StatusOK {
+		mock.Status = resp.StatusCode
+	}
+	headers := map[string]string{}
+	for k, v := range resp.Header {
+		if strings.HasPrefix(k, "X-") || containsFold(recordedHeaders, k) {
+			headers[k] = v[0]
+		}
+	}
+
+	ct := resp.Header.Get("Content-Type")
+	mediaType, _, _ := mime.ParseMediaType(ct)
+	switch {
+	case len(data) == 0:
+	case json.Valid(data) && (ct == "" || strings.Contains(mediaType, "json")):
+		mock.Body = data
+	case isTextContentType(ct):
+		text := string(data)
+		mock.RawBody = &text
+	default:
+		return nil, fmt.Errorf("binary body (%s)", ct)
+	}
+	// The default Content-Type is JSON, so only keep other types
+	if strings.Contains(mediaType, "json") {
+		delete(headers, "Content-Type")
+	}
+	if len(headers) > 0 {
+		mock.Headers = headers
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	err := enc.Encode(mock)
+	return buf.Bytes(), err
+}
+
+// Mock file (relative to baseDir) a request path is recorded to:
+// /users/42 -> users/42.json, / -> index.json. An id-like segment
+// becomes _ when the directory already has another id-like entry, so
+// /users/43 is recorded to users/_.json next to users/42.json.
+func recordPath(baseDir, urlPath string) (string, error) {
+	clean := path.Clean("/" + urlPath)
+	if strings.HasSuffix(urlPath, "/") || clean == "/" {
+		clean = path.Join(clean, "index")
+	}
+	segments := strings.Split(strings.TrimPrefix(clean, "/"), "/")
+	dir := baseDir
+	for i, seg := range segments {
+		if seg == "" || strings.HasPrefix(seg, ".") || strings.Contains(seg, queryLabelSep) {
+			return "", fmt.Errorf("cannot map path segment '%s' to a file", seg)
+		}
+		if idSegmentRe.MatchString(seg) && hasOtherIDEntry(dir, seg) {
+			segments[i] = "_"
+		}
+		dir = filepath.Join(dir, segments[i])
+	}
+	return strings.Join(segments, "/") + ".json", nil
+}
+
+// Whether dir has a file or directory for another id-like segment (or _)
+func hasOtherIDEntry(dir, seg string) bool {
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		name := e.Name()
+		if !e.IsDir() {
+			if !isMockFile(name) {
+				continue
+			}
+			name, _ = splitMockName(name)
+		}
+		if name != seg && (name == "_" || idSegmentRe.MatchString(name)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Write a file through a temporary file, so the server and the watcher
+// never see it half written
+func writeFileAtomic(file string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(file), ".record-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
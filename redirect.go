@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// Redirect response
+type Redirect struct {
+	URL          string            `json:"url"`          // Absolute or relative target (templates allowed)
+	Status       int               `json:"status"`       // 3xx (default: 302)
+	ForwardQuery []string          `json:"forwardQuery"` // Incoming query parameters copied to the target
+	Query        map[string]string `json:"query"`        // Query parameters added to the target (templates allowed)
+}
+
+func (rd *Redirect) status() int {
+	if rd.Status >= 300 && rd.Status <= 399 {
+		return rd.Status
+	}
+	return http.StatusFound
+}
+
+// Build the Location of a redirect. Parameters already in the target URL
+// are kept unless overwritten by forwarded or added ones.
+func (rd *Redirect) location(r *http.Request, td *templateData) (string, error) {
+	u, err := url.Parse(td.expand(rd.URL))
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	incoming := r.URL.Query()
+	for _, name := range rd.ForwardQuery {
+		if incoming.Has(name) {
+			q[name] = incoming[name]
+		}
+	}
+	for k, v := range rd.Query {
+		q.Set(k, td.expand(v))
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// {"$repeat": {...}} in a body is replaced with an array of generated items
+type repeatSpec struct {
+	Min  interface{}     `json:"min"`  // Number, or "query.NAME" / "path.N"
+	Max  interface{}     `json:"max"`  // Number, or "query.NAME" / "path.N"
+	Name string          `json:"name"` // Exposes the count as {repeat.NAME.count}
+	Item json.RawMessage `json:"item"` // Template of one item ({repeat.index} is its index)
+}
+
+// Upper limit of the items generated for a body, by all its $repeat
+// constructs together (nested ones multiply), so that a bound taken from
+// the request (e.g. ?per_page=100000000) cannot exhaust the memory
+const maxRepeatCount = 1000
+
+// Expand every $repeat construct in the body. The generated counts are
+// recorded in td for {repeat.count} / {repeat.NAME.count}.
+func expandRepeats(body json.RawMessage, r *http.Request, td *templateData) json.RawMessage {
+	if !bytes.Contains(body, []byte(`"$repeat"`)) {
+		return body
+	}
+	return td.repeatJSON(body, r)
+}
+
+// Replace the $repeat objects of a JSON value, keeping the rest as written
+func (td *templateData) repeatJSON(raw json.RawMessage, r *http.Request) json.RawMessage {
+	out, _ := rewriteJSON(raw, func(v json.RawMessage) (json.RawMessage, bool, error) {
+		members, ok := jsonObjectMembers(v)
+		if !ok || len(members) != 1 || members[0].Key != "$repeat" {
+			return nil, false, nil
+		}
+		return td.generateRepeat(members[0].Value, r), true, nil
+	})
+	return out
+}
+
+func (td *templateData) generateRepeat(raw json.RawMessage, r *http.Request) json.RawMessage {
+	var spec repeatSpec
+	json.Unmarshal(raw, &spec)
+	if len(spec.Item) == 0 {
+		spec.Item = json.RawMessage("null")
+	}
+
+	min := td.repeatBound(spec.Min, 0)
+	max := td.repeatBound(spec.Max, min)
+	if max < min {
+		max = min
+	}
+	count := randomInt(min, max)
+	if left := maxRepeatCount - td.repeatItems; count > left {
+		count = left
+	}
+	td.repeatItems += count
+
+	td.repeatCounts[""] = count
+	if spec.Name != "" {
+		td.repeatCounts[spec.Name] = count
+	}
+
+	items := make([]json.RawMessage, 0, count)
+	for i := 0; i < count; i++ {
+		item := replaceRepeatIndex(spec.Item, strconv.Itoa(i))
+		items = append(items, td.repeatJSON(item, r))
+	}
+	return encodeJSONArray(items)
+}
+
+// Replace {repeat.index} in the strings (and keys) of an item, except in
+// nested $repeat objects, whose items get their own index
+func replaceRepeatIndex(raw json.RawMessage, index string) json.RawMessage {
+	if !bytes.Contains(raw, []byte("{repeat.index}")) {
+		return raw
+	}
+	out, _ := rewriteJSON(raw, func(v json.RawMessage) (json.RawMessage, bool, error) {
+		if !bytes.Contains(v, []byte(`"$repeat"`)) {
+			// Nothing nested to skip: keep the bytes around the tokens
+			return json.RawMessage(mapJSONStrings(string(v), "{repeat.index}", func(str string) (string, bool) {
+				return quoteJSON(strings.ReplaceAll(str, "{repeat.index}", index)), true
+			})), true, nil
+		}
+		members, ok := jsonObjectMembers(v)
+		if !ok {
+			return nil, false, nil
+		}
+		if len(members) == 1 && members[0].Key == "$repeat" {
+			return v, true, nil
+		}
+		for i, m := range members {
+			members[i].Key = strings.ReplaceAll(m.Key, "{repeat.index}", index)
+			members[i].Value = replaceRepeatIndex(m.Value, index)
+		}
+		return encodeJSONObject(members), true, nil
+	})
+	return out
+}
+
+// Numeric bound from a number or a "query.NAME" / "path.N" reference,
+// clamped to 0..maxRepeatCount
+func (td *templateData) repeatBound(v interface{}, def int) int {
+	n := def
+	switch b := v.(type) {
+	case float64:
+		n = int(math.Max(math.Min(b, maxRepeatCount), 0))
+	case string:
+		// Out of range values come back as the largest int of their sign
+		if i, err := strconv.Atoi(lookupValue(b, td.r, td.pathParams)); err == nil || errors.Is(err, strconv.ErrRange) {
+			n = i
+		}
+	}
+	if n < 0 {
+		return 0
+	}
+	if n > maxRepeatCount {
+		return maxRepeatCount
+	}
+	return n
+}
+
+// Replace {repeat.count} (last generated array) and {repeat.NAME.count}
+func (td *templateData) replaceRepeatCounts(s string) string {
+	if len(td.repeatCounts) == 0 || !strings.Contains(s, "{repeat.") {
+		return s
+	}
+	for name, count := range td.repeatCounts {
+		token := "{repeat.count}"
+		if name != "" {
+			token = "{repeat." + name + ".count}"
+		}
+		s = strings.ReplaceAll(s, token, strconv.Itoa(count))
+	}
+	return s
+}
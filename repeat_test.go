@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestRepeatBoundFromRequest(t *testing.T) {
+	newMockDir(t, map[string]string{
+		"users.json": `{"body": {"users": {"$repeat": {"min": "query.n", "max": "query.n", "item": {"i": "{repeat.index}"}}}, "count": "{repeat.count}"}}`,
+	})
+
+	tests := []struct {
+		query string
+		count int
+	}{
+		{"n=3", 3},
+		{"n=-5", 0},
+		{"n=abc", 0},
+		{"n=1.5", 0},
+		{"", 0},
+		{"n=5000", maxRepeatCount},
+		{"n=99999999999999999999999", maxRepeatCount},
+		{"n=-99999999999999999999999", 0},
+	}
+	for _, tt := range tests {
+		rec := serve(t, newRequest("GET", "/users?"+tt.query, ""))
+		var body struct {
+			Users []map[string]string `json:"users"`
+			Count string              `json:"count"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("?%s: %v (%s)", tt.query, err, rec.Body.String())
+		}
+		if len(body.Users) != tt.count {
+			t.Errorf("?%s: %d items, want %d", tt.query, len(body.Users), tt.count)
+		}
+		if len(body.Users) > 0 && body.Users[len(body.Users)-1]["i"] == "" {
+			t.Errorf("?%s: {repeat.index} not replaced: %v", tt.query, body.Users[0])
+		}
+	}
+}
+
+func TestRepeatBoundFromNumber(t *testing.T) {
+	td := &templateData{}
+	tests := []struct {
+		v    interface{}
+		want int
+	}{
+		{float64(4), 4},
+		{float64(-1), 0},
+		{float64(1e30), maxRepeatCount},
+		{nil, 2},
+	}
+	for _, tt := range tests {
+		if got := td.repeatBound(tt.v, 2); got != tt.want {
+			t.Errorf("repeatBound(%v) = %d, want %d", tt.v, got, tt.want)
+		}
+	}
+}
+
+func TestRepeatKeepsSurroundingJSON(t *testing.T) {
+	td := &templateData{repeatCounts: map[string]int{}}
+	body := `{"z": 1, "big": 12345678901234567890, "html": "<a&b>", "list": {"$repeat": {"min": 2, "max": 2, "item": {"b": 1, "a": "{repeat.index}"}}}}`
+	got := string(expandRepeats(json.RawMessage(body), newRequest("GET", "/", ""), td))
+	want := `{"z":1,"big":12345678901234567890,"html":"<a&b>","list":[{"b": 1, "a": "0"},{"b": 1, "a": "1"}]}`
+	if got != want {
+		t.Errorf("expandRepeats = %s, want %s", got, want)
+	}
+	if !strings.Contains(string(expandRepeats(json.RawMessage(`{"a": 1}`), nil, td)), `"a": 1`) {
+		t.Error("a body without $repeat was re-encoded")
+	}
+}
+
+func TestRepeatNested(t *testing.T) {
+	td := &templateData{repeatCounts: map[string]int{}}
+	body := `{"$repeat": {"min": 2, "max": 2, "item": {"i": "{repeat.index}", "children": {"$repeat": {"min": 3, "max": 3, "item": "{repeat.index}"}}}}}`
+	got := string(expandRepeats(json.RawMessage(body), newRequest("GET", "/", ""), td))
+	want := `[{"i":"0","children":["0","1","2"]},{"i":"1","children":["0","1","2"]}]`
+	if got != want {
+		t.Errorf("expandRepeats = %s, want %s", got, want)
+	}
+
+	// Nested counts share one limit instead of multiplying
+	td = &templateData{repeatCounts: map[string]int{}}
+	body = `{"$repeat": {"min": 1000, "max": 1000, "item": {"$repeat": {"min": 1000, "max": 1000, "item": 1}}}}`
+	var outer [][]int
+	if err := json.Unmarshal(expandRepeats(json.RawMessage(body), newRequest("GET", "/", ""), td), &outer); err != nil {
+		t.Fatal(err)
+	}
+	total := len(outer)
+	for _, inner := range outer {
+		total += len(inner)
+	}
+	if total > maxRepeatCount {
+		t.Errorf("%d items generated, want at most %d", total, maxRepeatCount)
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers that describe the original connection, not the request
+var replaySkipHeaders = map[string]bool{
+	"Host":              true,
+	"Content-Length":    true,
+	"Connection":        true,
+	"Accept-Encoding":   true,
+	"Transfer-Encoding": true,
+}
+
+// Reissue every request of a request log and diff the responses
+func runReplay(args []string) {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	target := fs.String("target", "", "Base URL to send requests to (if empty, this apimock: http://localhost:<port>)")
+	ignoreFields := fs.String("ignore", "", "Comma-separated JSON body fields to skip when comparing (e.g. id,meta.requestId)")
+	timeout := fs.Duration("timeout", 10*time.Second, "Timeout of each request")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: apimock replay <request log> [options]")
+		fmt.Fprintln(fs.Output(), "Example: apimock replay requests.log --target http://localhost:8080")
+		fs.PrintDefaults()
+	}
+
+	// The log file comes first, flags follow
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		fs.Usage()
+		os.Exit(2)
+	}
+	logPath := args[0]
+	fs.Parse(args[1:])
+
+	if *target == "" {
+		loadConfigFiles()
+		*target = serverURL(configPort)
+	}
+	base := strings.TrimSuffix(*target, "/")
+
+	var ignored []string
+	if *ignoreFields != "" {
+		ignored = strings.Split(*ignoreFields, ",")
+	}
+
+	// Read the whole log first: replaying against an apimock that logs to
+	// the same file must not pick up the replayed requests
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot read request log: %v\n", err)
+		os.Exit(1)
+	}
+
+	client := &http.Client{
+		Timeout: *timeout,
+		// Redirects are compared as recorded, not followed
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+
+	var total, mismatches int
+	for i, line := range bytes.Split(data, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var entry loggedRequest
+		if err := json.Unmarshal(line, &entry); err != nil {
+			fmt.Printf("[ERROR] line %d: %v\n", i+1, err)
+			mismatches++
+			continue
+		}
+		total++
+
+		diffs, err := replayRequest(client, base, entry, ignored)
+		if err != nil {
+			fmt.Printf("FAIL %s %s: %v\n", entry.Method, entry.URL, err)
+			mismatches++
+			continue
+		}
+		if len(diffs) > 0 {
+			fmt.Printf("FAIL %s %s\n", entry.Method, entry.URL)
+			for _, d := range diffs {
+				fmt.Printf("    %s\n", d)
+			}
+			mismatches++
+		}
+	}
+	if mismatches > 0 {
+		fmt.Printf("%d of %d request(s) mismatched\n", mismatches, total)
+		os.Exit(1)
+	}
+	fmt.Printf("All %d request(s) matched\n", total)
+}
+
+// Send one logged request and describe how the response differs
+func replayRequest(client *http.Client, base string, entry loggedRequest, ignored []string) ([]string, error) {
+	req, err := http.NewRequest(entry.Method, base+entry.URL, bytes.NewReader(loggedBody(entry.Body, entry.Base64)))
+	if err != nil {
+		return nil, err
+	}
+	for k, values := range entry.Headers {
+		if replaySkipHeaders[http.CanonicalHeaderKey(k)] {
+			continue
+		}
+		for _, v := range values {
+			req.Header.Add(k, v)
+		}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var diffs []string
+	if resp.StatusCode != entry.Response.Status {
+		diffs = append(diffs, fmt.Sprintf("status: expected %d, got %d", entry.Response.Status, resp.StatusCode))
+	}
+	return append(diffs, diffBodies(string(loggedBody(entry.Response.Body, entry.Response.Base64)), string(body), ignored)...), nil
+}
+
+// Compare bodies as JSON when both are JSON, otherwise byte for byte
+func diffBodies(expected, actual string, ignored []string) []string {
+	var want, got interface{}
+	if json.Unmarshal([]byte(expected), &want) != nil || json.Unmarshal([]byte(actual), &got) != nil {
+		if expected == actual {
+			return nil
+		}
+		return []string{fmt.Sprintf("body: expected %s, got %s", abbreviate(expected), abbreviate(actual))}
+	}
+
+	var diffs []string
+	diffJSON("", want, got, ignored, &diffs)
+	return diffs
+}
+
+// Collect the paths where two JSON values differ
+func diffJSON(path string, want, got interface{}, ignored []string, diffs *[]string) {
+	for _, p := range ignored {
+		if path == strings.TrimSpace(p) {
+			return
+		}
+	}
+
+	name := path
+	if name == "" {
+		name = "(root)"
+	}
+	switch w := want.(type) {
+	case map[string]interface{}:
+		g, ok := got.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := sortedKeys(w)
+		for k := range g {
+			if _, ok := w[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			child := k
+			if path != "" {
+				child = path + "." + k
+			}
+			wv, inWant := w[k]
+			gv, inGot := g[k]
+			switch {
+			case !inGot:
+				*diffs = append(*diffs, fmt.Sprintf("body.%s: missing (expected %s)", child, jsonText(wv)))
+			case !inWant:
+				*diffs = append(*diffs, fmt.Sprintf("body.%s: unexpected %s", child, jsonText(gv)))
+			default:
+				diffJSON(child, wv, gv, ignored, diffs)
+			}
+		}
+		return
+	case []interface{}:
+		g, ok := got.([]interface{})
+		if !ok {
+			break
+		}
+		if len(w) != len(g) {
+			*diffs = append(*diffs, fmt.Sprintf("body.%s: expected %d item(s), got %d", name, len(w), len(g)))
+			return
+		}
+		for i := range w {
+			child := strconv.Itoa(i)
+			if path != "" {
+				child = path + "." + child
+			}
+			diffJSON(child, w[i], g[i], ignored, diffs)
+		}
+		return
+	}
+	if !reflect.DeepEqual(want, got) {
+		*diffs = append(*diffs, fmt.Sprintf("body.%s: expected %s, got %s", name, jsonText(want), jsonText(got)))
+	}
+}
+
+func jsonText(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return abbreviate(string(b))
+}
+
+// Shorten long values in the report
+func abbreviate(s string) string {
+	if len(s) > 80 {
+		return s[:77] + "..."
+	}
+	return s
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// One line of the request log (JSON Lines)
+type loggedRequest struct {
+	Time     time.Time           `json:"time"`
+	Method   string              `json:"method"`
+	URL      string              `json:"url"`
+	Headers  map[string][]string `json:"headers,omitempty"`
+	Body     string              `json:"body,omitempty"`
+	Base64   bool                `json:"bodyBase64,omitempty"` // Body is base64 encoded
+	Response loggedResponse      `json:"response"`
+}
+
+type loggedResponse struct {
+	Status  int                 `json:"status"`
+	Headers map[string][]string `json:"headers,omitempty"`
+	Body    string              `json:"body,omitempty"`
+	Base64  bool                `json:"bodyBase64,omitempty"` // Body is base64 encoded
+}
+
+// Logged form of a body: the text, or base64 if it is not valid UTF-8,
+// which a JSON string cannot hold byte for byte
+func logBody(b []byte) (string, bool) {
+	if utf8.Valid(b) {
+		return string(b), false
+	}
+	return base64.StdEncoding.EncodeToString(b), true
+}
+
+// Bytes of a logged body
+func loggedBody(body string, isBase64 bool) []byte {
+	if !isBase64 {
+		return []byte(body)
+	}
+	b, err := base64.StdEncoding.DecodeString(body)
+	if err != nil {
+		return []byte(body)
+	}
+	return b
+}
+
+var (
+	requestLogMu   sync.Mutex
+	requestLogFile *os.File
+)
+
+// Captures the status and body written by a handler
+type recordingWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *recordingWriter) WriteHeader(status int) {
+	// Informational responses (e.g. 100 Continue) are not the final status
+	if w.status == 0 && status >= 200 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *recordingWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Append every request and its response to the request log
+func recordRequests(next http.HandlerFunc) http.HandlerFunc {
+	if configRequestLog == "" {
+		return next
+	}
+	f, err := os.OpenFile(configRequestLog, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		fatalf("Cannot open request log '%s': %v", configRequestLog, err)
+	}
+	requestLogFile = f
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		// The body is captured as the handler reads it, so Expect: 100-continue
+		// handling is not affected
+		var reqBody bytes.Buffer
+		body := r.Body
+		tee := io.TeeReader(body, &reqBody)
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{tee, body}
+
+		rec := &recordingWriter{ResponseWriter: w}
+		next(rec, r)
+
+		// Capture the rest of a body the handler did not read (unless the
+		// client is still waiting for 100 Continue and may never send it,
+		// or the body was rejected as too large)
+		if !expectsContinue(r) && rec.status != http.StatusRequestEntityTooLarge {
+			io.Copy(io.Discard, io.LimitReader(tee, int64(maxBodySize-reqBody.Len())))
+		}
+
+		entry := loggedRequest{
+			Time:     time.Now(),
+			Method:   r.Method,
+			URL:      r.URL.RequestURI(),
+			Headers:  r.Header,
+			Response: loggedResponse{Status: rec.status, Headers: w.Header()},
+		}
+		entry.Body, entry.Base64 = logBody(reqBody.Bytes())
+		entry.Response.Body, entry.Response.Base64 = logBody(rec.body.Bytes())
+		line, _ := json.Marshal(entry)
+
+		requestLogMu.Lock()
+		defer requestLogMu.Unlock()
+		requestLogFile.Write(append(line, '\n'))
+	}
+}
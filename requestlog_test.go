@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Log entries written by recordRequests to a temporary request log
+func withRequestLog(t *testing.T) (http.HandlerFunc, func() []loggedRequest) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "requests.log")
+	setConfig(t, &configRequestLog, path)
+	handler := recordRequests(mockHandler)
+	t.Cleanup(func() { requestLogFile.Close() })
+
+	return handler, func() []loggedRequest {
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		var entries []loggedRequest
+		sc := bufio.NewScanner(f)
+		for sc.Scan() {
+			var e loggedRequest
+			if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+				t.Fatal(err)
+			}
+			entries = append(entries, e)
+		}
+		return entries
+	}
+}
+
+func TestRequestLogBinaryBody(t *testing.T) {
+	newMockDir(t, map[string]string{"upload.POST.json": `{"status": 201, "rawBody": "ok"}`})
+	handler, entries := withRequestLog(t)
+
+	body := []byte{0xff, 0xfe, 0x00, 'a', 0x80}
+	handler(httptest.NewRecorder(), httptest.NewRequest("POST", "/upload", bytes.NewReader(body)))
+	handler(httptest.NewRecorder(), httptest.NewRequest("POST", "/upload", bytes.NewReader([]byte("héllo"))))
+
+	logged := entries()
+	if len(logged) != 2 {
+		t.Fatalf("%d entries, want 2", len(logged))
+	}
+	if !logged[0].Base64 || !bytes.Equal(loggedBody(logged[0].Body, logged[0].Base64), body) {
+		t.Errorf("binary body logged as %q (base64 %v)", logged[0].Body, logged[0].Base64)
+	}
+	if logged[1].Base64 || logged[1].Body != "héllo" {
+		t.Errorf("text body logged as %q (base64 %v)", logged[1].Body, logged[1].Base64)
+	}
+
+	// The replay sends the same bytes
+	var received []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received, _ = io.ReadAll(r.Body)
+		w.WriteHeader(201)
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+	diffs, err := replayRequest(srv.Client(), srv.URL, logged[0], nil)
+	if err != nil || len(diffs) > 0 {
+		t.Fatalf("replay: %v %v", diffs, err)
+	}
+	if !bytes.Equal(received, body) {
+		t.Errorf("replay sent %v, want %v", received, body)
+	}
+}
+
+// Reader of n bytes counting how many were read
+type countingReader struct {
+	n, read int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	if c.read >= c.n {
+		return 0, io.EOF
+	}
+	if len(p) > c.n-c.read {
+		p = p[:c.n-c.read]
+	}
+	for i := range p {
+		p[i] = 'x'
+	}
+	c.read += len(p)
+	return len(p), nil
+}
+
+func TestRequestLogSkipsRejectedBody(t *testing.T) {
+	newMockDir(t, map[string]string{"upload.POST.json": `{"status": 201}`})
+	setConfig(t, &configMaxRequestBody, 1024)
+	handler, entries := withRequestLog(t)
+
+	body := &countingReader{n: 5 << 20}
+	req := httptest.NewRequest("POST", "/upload", body)
+	req.ContentLength = int64(body.n)
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status %d, want 413", rec.Code)
+	}
+	if body.read > 64<<10 {
+		t.Errorf("%d bytes of the rejected body were read", body.read)
+	}
+	if logged := entries(); len(logged) != 1 || logged[0].Response.Status != 413 {
+		t.Errorf("entries = %+v", logged)
+	}
+}
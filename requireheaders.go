@@ -0,0 +1,45 @@
+package main
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// Reject a request lacking any of configRequireHeaders, unless its path is
+// exempt. Returns false if the request was rejected (the response has
+// been written).
+func checkRequiredHeaders(w http.ResponseWriter, r *http.Request) bool {
+	if len(configRequireHeaders) == 0 || pathExempt(r.URL.Path) {
+		return true
+	}
+
+	var missing []string
+	for _, h := range configRequireHeaders {
+		if h = strings.TrimSpace(h); r.Header.Get(h) == "" {
+			missing = append(missing, h)
+		}
+	}
+	if len(missing) == 0 {
+		return true
+	}
+
+	writeErrorResponse(w, configRequireHeadersResponse, http.StatusBadRequest,
+		map[string]interface{}{"error": "Missing required header", "missing": missing},
+		"{missing}", strings.Join(missing, ", "))
+	return false
+}
+
+// Match against configRequireHeadersExempt: "*" matches within a path
+// segment, "**" across segments (e.g. "/healthz", "/internal/**")
+func pathExempt(urlPath string) bool {
+	for _, pattern := range configRequireHeadersExempt {
+		expr := regexp.QuoteMeta(pattern)
+		expr = strings.ReplaceAll(expr, `\*\*`, `.*`)
+		expr = strings.ReplaceAll(expr, `\*`, `[^/]*`)
+		if re, err := compileCached("^" + expr + "$"); err == nil && re.MatchString(urlPath) {
+			return true
+		}
+	}
+	return false
+}
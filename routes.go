@@ -0,0 +1,50 @@
+package main
+
+import (
+	"sort"
+	"sync"
+)
+
+// Routes disabled at runtime (keyed by routeKey). Kept in memory only.
+var (
+	disabledMu     sync.RWMutex
+	disabledRoutes = map[string]bool{}
+)
+
+func isRouteDisabled(route string) bool {
+	disabledMu.RLock()
+	defer disabledMu.RUnlock()
+	return disabledRoutes[route]
+}
+
+func setRouteDisabled(route string, disabled bool) {
+	disabledMu.Lock()
+	defer disabledMu.Unlock()
+	if disabled {
+		disabledRoutes[route] = true
+	} else {
+		delete(disabledRoutes, route)
+	}
+}
+
+// Enable all disabled routes again
+func resetDisabledRoutes() {
+	disabledMu.Lock()
+	defer disabledMu.Unlock()
+	disabledRoutes = map[string]bool{}
+}
+
+// All mock files as route keys, sorted, after the inline routes
+func listRoutes() []string {
+	var routes []string
+	walkMockFiles(configDir, func(path string) {
+		routes = append(routes, routeKey(path))
+	})
+	sort.Strings(routes)
+
+	inline := make([]string, 0, len(configInlineRoutes)+len(routes))
+	for _, route := range configInlineRoutes {
+		inline = append(inline, inlinePrefix+route.Path)
+	}
+	return append(inline, routes...)
+}
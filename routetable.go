@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// Print every route with its methods, default status and file (--routes)
+func printRoutes() {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PATTERN\tMETHODS\tSTATUS\tFILE")
+	for _, route := range configInlineRoutes {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", "/"+strings.Trim(route.Path, "/"),
+			routeMethods(route.Method), routeStatus(route.Status), inlinePrefix+route.Path)
+	}
+	walkMockFiles(configDir, func(path string) {
+		key := routeKey(path)
+		methods, status := describeMockFile(path)
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", routePattern(key), methods, status, key)
+	})
+	tw.Flush()
+}
+
+// Methods and default status of a mock file as shown by --routes
+func describeMockFile(path string) (methods, status string) {
+	_, fileMethod := splitMockName(filepath.Base(path))
+	if isGzipFixture(path) {
+		return routeMethods([]string{fileMethod}), "200"
+	}
+	data, err := readMockFile(path)
+	if err == nil {
+		data, err = withDefaults(path, data)
+	}
+	if err != nil {
+		return "?", "invalid"
+	}
+
+	var mock MockResponse
+	var obj map[string]json.RawMessage
+	if entries, ok := parseMockEntries(data); ok {
+		for _, e := range entries {
+			mock.Method = append(mock.Method, e.Method...)
+		}
+		status = "varies"
+	} else if json.Unmarshal(data, &obj) != nil && !configLegacyRawFallback && !isSimpleModeBody(data) {
+		return "?", "invalid"
+	} else if !hasMockField(obj) {
+		status = "200" // Simple mode or served as is
+	} else if json.Unmarshal(data, &mock) != nil {
+		return "?", "invalid"
+	} else {
+		status = routeStatus(mock.Status)
+	}
+	if fileMethod != "" {
+		return fileMethod, status
+	}
+	return routeMethods(mock.Method), status
+}
+
+func routeMethods(methods []string) string {
+	seen := map[string]bool{}
+	var list []string
+	for _, m := range methods {
+		if m != "" && !seen[m] {
+			seen[m] = true
+			list = append(list, m)
+		}
+	}
+	if len(list) == 0 {
+		return "ANY"
+	}
+	return strings.Join(list, ",")
+}
+
+func routeStatus(status int) string {
+	if status == 0 {
+		status = configDefaultStatus
+	}
+	return strconv.Itoa(status)
+}
+
+// Print the mock that answers a request such as "GET /users/1?page=2"
+// (--match). The method defaults to GET. Exits with 1 if none matches.
+func printMatch(request string) {
+	method, target, ok := strings.Cut(strings.TrimSpace(request), " ")
+	if !ok {
+		method, target = "GET", method
+	}
+	u, err := url.Parse(strings.TrimSpace(target))
+	if err != nil {
+		fmt.Printf("Invalid path '%s': %v\n", target, err)
+		exit(2)
+	}
+	method = strings.ToUpper(method)
+	requestPath := normalizeRequestPath(u.Path)
+
+	if _, key, params, ok := matchInlineRoute(requestPath); ok {
+		fmt.Printf("%s %s -> %s\nparams: %v\n", method, u.RequestURI(), key, params)
+		return
+	}
+	lookup := findMockFile(configDir, requestPath, method, u.Query())
+	filePath, params := lookup.path, lookup.params
+	if filePath == "" {
+		if len(lookup.methods) > 0 {
+			fmt.Printf("%s %s -> 405 Method Not Allowed (allowed: %s)\n", method, u.RequestURI(), strings.Join(lookup.methods, ", "))
+		} else {
+			fmt.Printf("%s %s -> no mock file (404)\n", method, u.RequestURI())
+		}
+		exit(1)
+	}
+	fmt.Printf("%s %s -> %s\nparams: %v\n", method, u.RequestURI(), routeKey(filePath), params)
+}
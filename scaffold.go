@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Skeleton written by "apimock new" (field order matches the README)
+type mockTemplate struct {
+	Method  []string          `json:"method"`
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers"`
+	Body    map[string]string `json:"body"`
+}
+
+// apimock new <route> [--method GET POST] [--status 200] [--dir mock] [--force]
+func runNew(args []string) {
+	fs := flag.NewFlagSet("new", flag.ExitOnError)
+	methods := fs.String("method", "GET", "Allowed HTTP methods (e.g. --method GET POST or --method GET,POST)")
+	status := fs.Int("status", 200, "Response status code")
+	dir := fs.String("dir", "", "Mock directory (if empty, use config file or default)")
+	force := fs.Bool("force", false, "Overwrite an existing file without asking")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: apimock new <route> [options]")
+		fmt.Fprintln(fs.Output(), "Example: apimock new /users/:id --method GET POST")
+		fs.PrintDefaults()
+	}
+
+	// The route comes first, flags follow
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		fs.Usage()
+		os.Exit(2)
+	}
+	route := args[0]
+
+	// Bare arguments are extra methods: --method GET POST
+	var extra []string
+	rest := args[1:]
+	for {
+		fs.Parse(rest)
+		if fs.NArg() == 0 {
+			break
+		}
+		extra = append(extra, fs.Arg(0))
+		rest = fs.Args()[1:]
+	}
+
+	var methodList []string
+	for _, m := range append(strings.Split(*methods, ","), extra...) {
+		if m = strings.ToUpper(strings.TrimSpace(m)); m != "" {
+			methodList = append(methodList, m)
+		}
+	}
+
+	loadConfigFiles()
+	if *dir != "" {
+		configDir = *dir
+	}
+
+	relPath, params := routeToFile(route)
+	filePath := filepath.Join(configDir, relPath)
+
+	if _, err := os.Stat(filePath); err == nil && !*force {
+		fmt.Printf("[WARNING] %s already exists. Overwrite? [y/N]: ", filePath)
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if a := strings.ToLower(strings.TrimSpace(answer)); a != "y" && a != "yes" {
+			fmt.Println("Aborted.")
+			return
+		}
+	}
+
+	// Prefill the body with the captured path parameters
+	body := map[string]string{}
+	for i, name := range params {
+		body[name] = fmt.Sprintf("{path.%d}", i)
+	}
+
+	data, _ := json.MarshalIndent(mockTemplate{
+		Method:  methodList,
+		Status:  *status,
+		Headers: map[string]string{},
+		Body:    body,
+	}, "", "  ")
+
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create directory: %v\n", err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(filePath, append(data, '\n'), 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write file: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Created %s\n", filePath)
+}
+
+// Convert a route pattern (/users/:id, /users/{id}) into a mock file path
+// and the names of its parameters (in {path.N} order)
+func routeToFile(route string) (string, []string) {
+	var parts, params []string
+	for _, seg := range strings.Split(strings.Trim(route, "/"), "/") {
+		switch {
+		case seg == "":
+			continue
+		case strings.HasPrefix(seg, ":"):
+			params = append(params, seg[1:])
+			seg = "_"
+		case strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}"):
+			params = append(params, seg[1:len(seg)-1])
+			seg = "_"
+		case seg == "_":
+			params = append(params, fmt.Sprintf("param%d", len(params)))
+		}
+		parts = append(parts, seg)
+	}
+	if len(parts) == 0 {
+		return "index.json", nil // Served for /
+	}
+	return filepath.Join(parts...) + ".json", params
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Fill properties missing from body with random values generated from
+// a JSON Schema file (relative to configDir)
+func fillFromSchema(body json.RawMessage, schemaPath string) (json.RawMessage, error) {
+	p, err := schemaFilePath(schemaPath)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	var schema map[string]interface{}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, fmt.Errorf("invalid schema %s: %w", schemaPath, err)
+	}
+
+	g := &schemaGenerator{root: schema}
+	return g.fill(body, schema, 0)
+}
+
+// Path of a schema file, which must be inside the mock directory (also
+// after resolving symlinks)
+func schemaFilePath(name string) (string, error) {
+	if !filepath.IsLocal(filepath.FromSlash(name)) {
+		return "", fmt.Errorf("schemaFill '%s' is outside the mock directory", name)
+	}
+	root, err := filepath.EvalSymlinks(configDir)
+	if err != nil {
+		return "", err
+	}
+	p, err := filepath.EvalSymlinks(filepath.Join(root, filepath.FromSlash(name)))
+	if err != nil {
+		return "", err
+	}
+	if rel, err := filepath.Rel(root, p); err != nil || !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("schemaFill '%s' is outside the mock directory", name)
+	}
+	return p, nil
+}
+
+type schemaGenerator struct {
+	root map[string]interface{} // For resolving local $ref
+}
+
+// Nesting limit for recursive schemas
+const maxSchemaDepth = 10
+
+// Keep the given value and fill in what it lacks. Present members keep
+// their order and bytes; missing ones are appended.
+func (g *schemaGenerator) fill(value json.RawMessage, schema map[string]interface{}, depth int) (json.RawMessage, error) {
+	schema = g.resolve(schema)
+	if trimmed := bytes.TrimSpace(value); len(trimmed) == 0 || string(trimmed) == "null" {
+		return marshalJSON(g.generate(schema, depth))
+	}
+
+	if members, ok := jsonObjectMembers(value); ok {
+		props, _ := schema["properties"].(map[string]interface{})
+		// Sorted so a fixed seed gives the same output
+		for _, name := range sortedKeys(props) {
+			if ps, ok := props[name].(map[string]interface{}); ok && depth < maxSchemaDepth {
+				filled, err := g.fill(jsonMemberValue(members, name), ps, depth+1)
+				if err != nil {
+					return nil, err
+				}
+				members = setJSONMember(members, name, filled)
+			}
+		}
+		return encodeJSONObject(members), nil
+	}
+	if elems, ok := jsonArrayElements(value); ok {
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i := range elems {
+				filled, err := g.fill(elems[i], items, depth+1)
+				if err != nil {
+					return nil, err
+				}
+				elems[i] = filled
+			}
+		}
+		return encodeJSONArray(elems), nil
+	}
+	return value, nil
+}
+
+// Generate a random value from a schema
+func (g *schemaGenerator) generate(schema map[string]interface{}, depth int) interface{} {
+	schema = g.resolve(schema)
+	if enum, ok := schema["enum"].([]interface{}); ok && len(enum) > 0 {
+		return enum[randomInt(0, len(enum)-1)]
+	}
+
+	typ, _ := schema["type"].(string)
+	if typ == "" {
+		if _, ok := schema["properties"]; ok {
+			typ = "object"
+		}
+	}
+
+	switch typ {
+	case "object":
+		obj := map[string]interface{}{}
+		if depth >= maxSchemaDepth {
+			return obj
+		}
+		props, _ := schema["properties"].(map[string]interface{})
+		for _, name := range sortedKeys(props) {
+			if ps, ok := props[name].(map[string]interface{}); ok {
+				obj[name] = g.generate(ps, depth+1)
+			}
+		}
+		return obj
+	case "array":
+		arr := []interface{}{}
+		items, ok := schema["items"].(map[string]interface{})
+		if !ok || depth >= maxSchemaDepth {
+			return arr
+		}
+		min, max := schemaInt(schema, "minItems", 1), schemaInt(schema, "maxItems", 3)
+		for i, n := 0, randomInt(min, max); i < n; i++ {
+			arr = append(arr, g.generate(items, depth+1))
+		}
+		return arr
+	case "integer":
+		return randomInt(schemaInt(schema, "minimum", 0), schemaInt(schema, "maximum", 1000))
+	case "number":
+		return randomFloat(float64(schemaInt(schema, "minimum", 0)), float64(schemaInt(schema, "maximum", 1000)))
+	case "boolean":
+		return randomInt(0, 1) == 1
+	case "string":
+		format, _ := schema["format"].(string)
+		return randomString(format)
+	}
+	return nil
+}
+
+// Follow a local $ref such as "#/definitions/User" or "#/components/schemas/User"
+func (g *schemaGenerator) resolve(schema map[string]interface{}) map[string]interface{} {
+	for i := 0; i < maxSchemaDepth; i++ {
+		ref, ok := schema["$ref"].(string)
+		if !ok || !strings.HasPrefix(ref, "#/") {
+			return schema
+		}
+		var cur interface{} = g.root
+		for _, part := range strings.Split(ref[2:], "/") {
+			m, ok := cur.(map[string]interface{})
+			if !ok {
+				return schema
+			}
+			cur = m[strings.ReplaceAll(strings.ReplaceAll(part, "~1", "/"), "~0", "~")]
+		}
+		next, ok := cur.(map[string]interface{})
+		if !ok {
+			return schema
+		}
+		schema = next
+	}
+	return schema
+}
+
+func schemaInt(schema map[string]interface{}, key string, def int) int {
+	if v, ok := schema[key].(float64); ok {
+		return int(v)
+	}
+	return def
+}
+
+var randomWords = []string{"alpha", "bravo", "charlie", "delta", "echo", "foxtrot", "golf", "hotel", "india", "juliet"}
+
+// Random string for a JSON Schema string format
+func randomString(format string) string {
+	switch format {
+	case "date-time":
+		return randomTime().Format(time.RFC3339)
+	case "date":
+		return randomTime().Format("2006-01-02")
+	case "email":
+		return fmt.Sprintf("%s%d@example.com", randomWords[randomInt(0, len(randomWords)-1)], randomInt(1, 999))
+	case "uuid":
+		return randomUUID()
+	case "uri", "url":
+		return fmt.Sprintf("https://example.com/%s", randomWords[randomInt(0, len(randomWords)-1)])
+	}
+	return fmt.Sprintf("%s-%d", randomWords[randomInt(0, len(randomWords)-1)], randomInt(1, 999))
+}
+
+// Random time in 2025 (not based on the clock, so a fixed seed gives the same output)
+func randomTime() time.Time {
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	return base.Add(time.Duration(randomInt(0, 365*24*60*60)) * time.Second)
+}
+
+// Random version 4 UUID
+func randomUUID() string {
+	var b [16]byte
+	for i := range b {
+		b[i] = byte(randomInt(0, 255))
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFillFromSchemaKeepsBody(t *testing.T) {
+	newMockDir(t, map[string]string{
+		"schemas/user.json": `{"type": "object", "properties": {
+			"id": {"type": "integer"}, "name": {"type": "string"}, "tags": {"type": "array", "items": {"type": "string"}}}}`,
+	})
+
+	body := `{"name": "<b>Tom & Jerry</b>", "big": 12345678901234567890, "price": 1.10, "id": 7}`
+	out, err := fillFromSchema(json.RawMessage(body), "schemas/user.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := string(out)
+	prefix := `{"name":"<b>Tom & Jerry</b>","big":12345678901234567890,"price":1.10,"id":7,"tags":[`
+	if !strings.HasPrefix(s, prefix) {
+		t.Errorf("fillFromSchema = %s, want prefix %s", s, prefix)
+	}
+}
+
+func TestFillFromSchemaGeneratesMissing(t *testing.T) {
+	newMockDir(t, map[string]string{
+		"schemas/user.json": `{"type": "object", "properties": {"id": {"type": "integer"}, "email": {"type": "string", "format": "email"}}}`,
+	})
+
+	for _, body := range []string{"", "null", `{"id": null}`} {
+		out, err := fillFromSchema(json.RawMessage(body), "schemas/user.json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var v map[string]interface{}
+		if err := json.Unmarshal(out, &v); err != nil {
+			t.Fatalf("fillFromSchema(%q) = %s: %v", body, out, err)
+		}
+		if _, ok := v["id"].(float64); !ok {
+			t.Errorf("fillFromSchema(%q) = %s, want a generated id", body, out)
+		}
+		if email, _ := v["email"].(string); !strings.HasSuffix(email, "@example.com") {
+			t.Errorf("fillFromSchema(%q) = %s, want a generated email", body, out)
+		}
+	}
+}
+
+func TestFillFromSchemaStaysInMockDir(t *testing.T) {
+	dir := newMockDir(t, map[string]string{"schemas/user.json": `{"type": "object"}`})
+	outside := filepath.Join(t.TempDir(), "secret.json")
+	if err := os.WriteFile(outside, []byte(`{"type": "object"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(dir, "schemas", "linked.json")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"../secret.json", "/etc/passwd", "schemas/../../secret.json", "schemas/linked.json"} {
+		if _, err := fillFromSchema(json.RawMessage(`{}`), name); err == nil || !strings.Contains(err.Error(), "outside the mock directory") {
+			t.Errorf("schemaFill %q: err = %v, want outside the mock directory", name, err)
+		}
+	}
+	if _, err := fillFromSchema(json.RawMessage(`{}`), "schemas/user.json"); err != nil {
+		t.Errorf("schemaFill inside the mock directory: %v", err)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"log"
+	"sync"
+)
+
+// Number of requests served by each sequence, by route
+type sequenceStore struct {
+	mu       sync.Mutex
+	counters map[string]int
+}
+
+var sequenceState = &sequenceStore{counters: map[string]int{}}
+
+// Return the current count of a route and advance it
+func (s *sequenceStore) next(route string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := s.counters[route]
+	s.counters[route] = n + 1
+	return n
+}
+
+// Start the sequence of a route (all routes if empty) over
+func (s *sequenceStore) reset(route string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if route == "" {
+		s.counters = map[string]int{}
+	} else {
+		delete(s.counters, route)
+	}
+}
+
+func (s *sequenceStore) snapshot() map[string]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	counters := make(map[string]int, len(s.counters))
+	for k, v := range s.counters {
+		counters[k] = v
+	}
+	return counters
+}
+
+// Pick the next entry of a sequence. Past the end, the last entry is
+// repeated, or with sequenceMode "cycle" the sequence starts over.
+func selectSequence(mock MockResponse, route, filePath string) MockResponse {
+	n := sequenceState.next(route)
+	switch mock.SequenceMode {
+	case "cycle":
+		return mock.Sequence[n%len(mock.Sequence)]
+	case "", "last":
+	default:
+		log.Printf("[WARNING] Unknown sequenceMode '%s' in %s", mock.SequenceMode, filePath)
+	}
+	if n >= len(mock.Sequence) {
+		n = len(mock.Sequence) - 1
+	}
+	return mock.Sequence[n]
+}
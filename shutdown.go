@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// How long in-flight requests (e.g. sleeping on a delay) may take to
+// finish after SIGINT/SIGTERM
+const shutdownTimeout = 10 * time.Second
+
+// Serve until SIGINT or SIGTERM, then stop accepting connections and wait
+// for in-flight requests. A second signal exits immediately (after
+// removing an extracted mock archive).
+func runServers(servers []*http.Server) {
+	if err := prepareTLS(servers); err != nil {
+		fatalf("%v", err)
+	}
+	errc := make(chan error, len(servers))
+	for _, srv := range servers {
+		go func() { errc <- listenAndServe(srv) }()
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	select {
+	case err := <-errc:
+		fatalf("%v", err)
+	case s := <-sig:
+		log.Printf("[apimock] Received %v, shutting down (waiting up to %s for in-flight requests)", s, shutdownTimeout)
+	}
+	go func() {
+		<-sig
+		log.Printf("[apimock] Received a second signal, exiting")
+		exit(1)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	var wg sync.WaitGroup
+	for _, srv := range servers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := srv.Shutdown(ctx); err != nil {
+				log.Printf("[WARNING] Closing remaining connections on %s: %v", srv.Addr, err)
+				srv.Close()
+			}
+		}()
+	}
+	wg.Wait()
+	for range servers {
+		if err := <-errc; err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("[WARNING] %v", err)
+		}
+	}
+	log.Printf("[apimock] Stopped")
+}
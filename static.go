@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Serve a file from configStaticDir, falling back to index.html for
+// page navigations (SPA routing). Returns false if nothing was served.
+func serveStatic(w http.ResponseWriter, r *http.Request) bool {
+	urlPath := path.Clean("/" + r.URL.Path)
+	full := filepath.Join(configStaticDir, filepath.FromSlash(urlPath))
+	if info, err := os.Stat(full); err == nil {
+		// Directories are served only through their index.html (no listings)
+		if !info.IsDir() || fileExists(filepath.Join(full, "index.html")) {
+			http.FileServer(http.Dir(configStaticDir)).ServeHTTP(w, r)
+			return true
+		}
+	}
+
+	// SPA fallback: only for browser navigations, so API clients still get 404
+	if r.Method != "GET" && r.Method != "HEAD" {
+		return false
+	}
+	if !strings.Contains(r.Header.Get("Accept"), "text/html") {
+		return false
+	}
+	index := filepath.Join(configStaticDir, "index.html")
+	f, err := os.Open(index)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	http.ServeContent(w, r, "index.html", info.ModTime(), f)
+	return true
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Send the body in chunks, flushing each one
+type Stream struct {
+	ChunkSize       int   `json:"chunkSize"`       // Bytes per chunk (default: one line per chunk)
+	InterChunkDelay Delay `json:"interChunkDelay"` // Milliseconds between chunks, fixed or {"min", "max"}
+}
+
+// Split a body into the chunks of a stream. Server-Sent Events are split
+// after each event (blank line), other bodies by chunkSize or by line.
+func (s *Stream) chunks(body, contentType string) []string {
+	var chunks []string
+	switch {
+	case strings.HasPrefix(strings.ToLower(contentType), "text/event-stream"):
+		body = strings.ReplaceAll(body, "\r\n", "\n")
+		for _, event := range strings.Split(body, "\n\n") {
+			if strings.TrimSpace(event) != "" {
+				chunks = append(chunks, strings.Trim(event, "\n")+"\n\n")
+			}
+		}
+	case s.ChunkSize > 0:
+		for len(body) > s.ChunkSize {
+			chunks = append(chunks, body[:s.ChunkSize])
+			body = body[s.ChunkSize:]
+		}
+		chunks = append(chunks, body)
+	default:
+		chunks = strings.SplitAfter(body, "\n")
+	}
+	return chunks
+}
+
+// Write a streamed response. Stops early if the client goes away.
+func writeStream(w http.ResponseWriter, r *http.Request, status int, s *Stream, body string) {
+	h := w.Header()
+	h.Del("Content-Length")
+	if strings.HasPrefix(strings.ToLower(h.Get("Content-Type")), "text/event-stream") && h.Get("Cache-Control") == "" {
+		h.Set("Cache-Control", "no-cache")
+	}
+	h.Set("X-Accel-Buffering", "no") // Keep reverse proxies (nginx) from buffering
+	w.WriteHeader(status)
+
+	flusher, _ := w.(http.Flusher)
+	for i, chunk := range s.chunks(body, h.Get("Content-Type")) {
+		if chunk == "" {
+			continue
+		}
+		if i > 0 {
+			if d := s.InterChunkDelay.duration(); d > 0 && !*noDelay {
+				select {
+				case <-time.After(d):
+				case <-r.Context().Done():
+					return
+				}
+			}
+		}
+		if _, err := w.Write([]byte(chunk)); err != nil {
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
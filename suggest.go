@@ -0,0 +1,81 @@
+package main
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+// Maximum number of suggestions in a 404 response
+const maxSuggestions = 3
+
+// URL pattern of a route key ("users/_/index.GET.json" -> "/users/_")
+func routePattern(route string) string {
+	dir, name := path.Split(route)
+	name, _ = splitMockName(name)
+	p := strings.TrimSuffix(dir+name, "index")
+	return "/" + strings.TrimSuffix(p, "/")
+}
+
+// Known routes close to the requested path (by edit distance)
+func suggestRoutes(requestPath string) []string {
+	reqParts := strings.Split(requestPath, "/")
+	limit := len(requestPath) / 2
+	if limit < 2 {
+		limit = 2
+	}
+
+	type candidate struct {
+		pattern  string
+		distance int
+	}
+	var candidates []candidate
+	seen := map[string]bool{}
+	for _, route := range listRoutes() {
+		pattern := routePattern(route)
+		if seen[pattern] {
+			continue
+		}
+		seen[pattern] = true
+
+		// A wildcard counts as the request's segment at the same position
+		parts := strings.Split(strings.TrimPrefix(pattern, "/"), "/")
+		for i := range parts {
+			if parts[i] == "_" && i < len(reqParts) {
+				parts[i] = reqParts[i]
+			}
+		}
+		if d := editDistance(requestPath, strings.Join(parts, "/")); d <= limit {
+			candidates = append(candidates, candidate{pattern, d})
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].distance < candidates[j].distance })
+	suggestions := []string{}
+	for i := 0; i < len(candidates) && i < maxSuggestions; i++ {
+		suggestions = append(suggestions, candidates[i].pattern)
+	}
+	return suggestions
+}
+
+// Levenshtein distance
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Per-request state of template expansion
+type templateData struct {
+	r            *http.Request
+	pathParams   []string          // Values of the _ segments of the route
+	catchAll     bool              // The last path param is the rest of a catch-all route
+	roundRobin   map[string]string // Values picked for this request, by counter name
+	repeatCounts map[string]int    // Sizes of $repeat arrays, by name ("" for the last one)
+	repeatItems  int               // Items generated by $repeat so far
+	trace        *traceSpan        // Trace context of the request (nil if disabled)
+
+	body       interface{} // Request body decoded as JSON (nil if not JSON)
+	bodyParsed bool
+	form       *requestForm // Form fields and file parts of the request body
+}
+
+func newTemplateData(r *http.Request, pathParams []string) *templateData {
+	return &templateData{r: r, pathParams: pathParams, roundRobin: map[string]string{}, repeatCounts: map[string]int{}}
+}
+
+// Set up the tokens that depend on the matched mock file ({path.rest})
+func (td *templateData) withRoute(filePath string) *templateData {
+	name, _ := splitMockName(filepath.Base(filePath))
+	if name == "index" {
+		name = filepath.Base(filepath.Dir(filePath))
+	}
+	td.catchAll = name == catchAllName && len(td.pathParams) > 0
+	return td
+}
+
+// Expand all template tokens in a header value or body. Request tokens
+// go last, so values sent by the client (e.g. ?q={uuid}) are inserted
+// as they are, never expanded themselves.
+func (td *templateData) expand(s string) string {
+	return td.replaceRequestTokens(td.expandGenerated(s))
+}
+
+// Expand the tokens whose values do not come from the request
+func (td *templateData) expandGenerated(s string) string {
+	s = replaceFlags(s)
+	s = replaceUUIDs(s)
+	s = replaceNow(s)
+	s = td.trace.replaceTokens(s)
+	s = td.replaceRepeatCounts(s)
+	return td.replaceRoundRobin(s)
+}
+
+// Expand all template tokens in a JSON body, escaping the values
+func (td *templateData) expandJSON(s string) string {
+	return expandJSONStrings(s, td.expand)
+}
+
+// Expand tokens in the string literals of a JSON text only. Expanded
+// strings are re-encoded, so a quote, backslash or newline in a value
+// (e.g. from {query.x}) cannot break the JSON or inject fields.
+func expandJSONStrings(s string, expand func(string) string) string {
+	if !strings.Contains(s, "{") {
+		return s
+	}
+	return mapJSONStrings(s, "{", func(str string) (string, bool) {
+		expanded := expand(str)
+		return quoteJSON(expanded), expanded != str
+	})
+}
+
+// Replace the string literals of a JSON text that contain marker with the
+// JSON returned by fn (literals for which it returns false are kept byte
+// for byte)
+func mapJSONStrings(s, marker string, fn func(str string) (string, bool)) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		if s[i] != '"' {
+			b.WriteByte(s[i])
+			i++
+			continue
+		}
+		end := i + 1
+		for end < len(s) && s[end] != '"' {
+			if s[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		if end >= len(s) { // Unterminated string (not JSON)
+			b.WriteString(s[i:])
+			break
+		}
+		lit := s[i : end+1]
+		var str string
+		if strings.Contains(lit, marker) && json.Unmarshal([]byte(lit), &str) == nil {
+			if replaced, ok := fn(str); ok {
+				lit = replaced
+			}
+		}
+		b.WriteString(lit)
+		i = end + 1
+	}
+	return b.String()
+}
+
+// JSON string literal of s (without escaping <, > and &)
+func quoteJSON(s string) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+var requestTokenRe = regexp.MustCompile(`\{(path|query|header|cookie|body|form|file)\.([^{}]+)\}`)
+
+// Replace tokens taken from the request: {path.N} (negative indexes count
+// from the end), {path.rest} (catch-all routes), {query.NAME}, {header.NAME} (case-insensitive), {cookie.NAME},
+// {body.KEY.KEY} (the JSON request body), {form.NAME} and {file.NAME.filename}
+// (form requests). Tokens without a value, and the response body tokens
+// handled by replaceBodyTokens, are left as is.
+func (td *templateData) replaceRequestTokens(s string) string {
+	if !strings.Contains(s, "{") {
+		return s
+	}
+	return requestTokenRe.ReplaceAllStringFunc(s, func(match string) string {
+		m := requestTokenRe.FindStringSubmatch(match)
+		switch kind, name := m[1], m[2]; kind {
+		case "path":
+			if name == "rest" && td.catchAll {
+				return td.pathParams[len(td.pathParams)-1]
+			}
+			idx, err := strconv.Atoi(name)
+			if err == nil && idx < 0 {
+				idx += len(td.pathParams) // {path.-1} is the last one
+			}
+			if err == nil && idx >= 0 && idx < len(td.pathParams) {
+				return td.pathParams[idx]
+			}
+		case "query":
+			if values := td.r.URL.Query()[name]; len(values) > 0 {
+				return values[0]
+			}
+		case "header":
+			if values := td.r.Header.Values(name); len(values) > 0 {
+				return values[0]
+			}
+		case "cookie":
+			if c, err := td.r.Cookie(name); err == nil {
+				return c.Value
+			}
+		case "body":
+			if name == "length" || strings.HasPrefix(name, "sha256") {
+				break
+			}
+			v := td.requestBody()
+			for _, key := range strings.Split(name, ".") {
+				v = member(v, key)
+			}
+			switch v := v.(type) {
+			case nil:
+			case string:
+				return v
+			default:
+				if data, err := json.Marshal(v); err == nil {
+					return string(data)
+				}
+			}
+		case "form":
+			if values := td.requestForm().values[name]; len(values) > 0 {
+				return values[0]
+			}
+		case "file":
+			if v, ok := td.requestForm().fileToken(name); ok {
+				return v
+			}
+		}
+		return match
+	})
+}
+
+// The request body decoded as JSON (nil if it is not JSON)
+func (td *templateData) requestBody() interface{} {
+	if !td.bodyParsed {
+		td.bodyParsed = true
+		if data, err := readBody(td.r); err == nil {
+			json.Unmarshal(data, &td.body)
+		}
+	}
+	return td.body
+}
+
+// Fields and file parts of a form request body (parsed once per request)
+func (td *templateData) requestForm() *requestForm {
+	if td.form == nil {
+		td.form, _ = requestFormOf(td.r)
+	}
+	return td.form
+}
+
+// Replace tokens computed from the final response body:
+// {body.length}, {body.sha256} (hex) and {body.sha256.base64}
+func replaceBodyTokens(s, body string) string {
+	if !strings.Contains(s, "{body.") {
+		return s
+	}
+	sum := sha256.Sum256([]byte(body))
+	return strings.NewReplacer(
+		"{body.length}", strconv.Itoa(len(body)),
+		"{body.sha256}", hex.EncodeToString(sum[:]),
+		"{body.sha256.base64}", base64.StdEncoding.EncodeToString(sum[:]),
+	).Replace(s)
+}
+
+// Replace each {uuid} with a new random UUID
+func replaceUUIDs(s string) string {
+	for strings.Contains(s, "{uuid}") {
+		s = strings.Replace(s, "{uuid}", randomUUID(), 1)
+	}
+	return s
+}
+
+var nowRe = regexp.MustCompile(`\{now(?:\.([+-]\d+)([smhdw]))?\}`)
+
+var nowUnits = map[string]time.Duration{
+	"s": time.Second,
+	"m": time.Minute,
+	"h": time.Hour,
+	"d": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour,
+}
+
+// Replace {now} and offsets such as {now.+30d} or {now.-2h} with an
+// HTTP-date (e.g. "Tue, 15 Nov 1994 08:12:31 GMT")
+func replaceNow(s string) string {
+	if !strings.Contains(s, "{now") {
+		return s
+	}
+	base := now()
+	return nowRe.ReplaceAllStringFunc(s, func(token string) string {
+		m := nowRe.FindStringSubmatch(token)
+		t := base
+		if m[1] != "" {
+			n, _ := strconv.Atoi(m[1])
+			t = base.Add(time.Duration(n) * nowUnits[m[2]])
+		}
+		return t.UTC().Format(http.TimeFormat)
+	})
+}
+
+var roundRobinRe = regexp.MustCompile(`\{roundrobin:([^{}]*)\}`)
+
+// Replace {roundrobin:a,b,c} with the next value of the counter named
+// "a,b,c". A counter advances at most once per request, so the same
+// token in headers and body gives the same value.
+func (td *templateData) replaceRoundRobin(s string) string {
+	return roundRobinRe.ReplaceAllStringFunc(s, func(match string) string {
+		name := roundRobinRe.FindStringSubmatch(match)[1]
+		if v, ok := td.roundRobin[name]; ok {
+			return v
+		}
+		values := strings.Split(name, ",")
+		v := values[roundRobinState.next(name)%uint64(len(values))]
+		td.roundRobin[name] = v
+		return v
+	})
+}
+
+// Round-robin counters, shared by all mocks for the server's lifetime
+type roundRobinCounters struct {
+	mu       sync.Mutex
+	counters map[string]uint64
+}
+
+var roundRobinState = &roundRobinCounters{counters: map[string]uint64{}}
+
+// Return the current count and advance it
+func (c *roundRobinCounters) next(name string) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := c.counters[name]
+	c.counters[name] = n + 1
+	return n
+}
+
+func (c *roundRobinCounters) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counters = map[string]uint64{}
+}
+
+func (c *roundRobinCounters) snapshot() map[string]uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	counters := make(map[string]uint64, len(c.counters))
+	for k, v := range c.counters {
+		counters[k] = v
+	}
+	return counters
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestExpandJSONEscapesValues(t *testing.T) {
+	newMockDir(t, map[string]string{
+		"search.json": `{"body": {"q": "{query.q}", "raw": "é {query.q}", "{query.key}": 1, "n": "{path.9}"}}`,
+	})
+	tests := []string{
+		`a","admin":true`,
+		`back\slash \" \\`,
+		"new\nline\ttab\x01",
+		"日本語 🎉 é",
+		"  ",
+		"{query.q}",
+	}
+	for _, q := range tests {
+		rec := serve(t, newRequest("GET", "/search?q="+url.QueryEscape(q)+"&key="+url.QueryEscape(q), ""))
+		var got map[string]interface{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Errorf("q=%q: invalid JSON %s: %v", q, rec.Body.String(), err)
+			continue
+		}
+		if got["q"] != q || got["raw"] != "é "+q || got[q] != 1.0 || len(got) != 4 {
+			t.Errorf("q=%q: got %v", q, got)
+		}
+		if got["n"] != "{path.9}" {
+			t.Errorf("q=%q: an unresolved token changed to %v", q, got["n"])
+		}
+	}
+}
+
+func TestExpandJSONKeepsUnexpandedStrings(t *testing.T) {
+	s := `{"a": "é\n", "b": "{unknown}", "c": "x"}`
+	if got := expandJSONStrings(s, func(v string) string { return v }); got != s {
+		t.Errorf("expandJSONStrings = %s, want the input unchanged", got)
+	}
+}
+
+func TestTemplateUUIDsDiffer(t *testing.T) {
+	newMockDir(t, map[string]string{
+		"users/_.json": `{
+			"template": true,
+			"headers": {"X-Request-Id": "{{uuid}}"},
+			"body": {"id": "{{uuid}}", "token": "{uuid}", "age": "{{randomInt 18 90 | json}}", "owner": "{path.0}"}
+		}`,
+		"literal.json": `{"body": {"text": "{{randomInt 1 9}}"}}`,
+	})
+	type user struct {
+		ID, Token, Owner string
+		Age              int
+	}
+	var got [2]user
+	var requestIDs [2]string
+	for i := range got {
+		rec := serve(t, newRequest("GET", "/users/taro", ""))
+		if err := json.Unmarshal(rec.Body.Bytes(), &got[i]); err != nil {
+			t.Fatalf("invalid JSON %s: %v", rec.Body.String(), err)
+		}
+		requestIDs[i] = rec.Header().Get("X-Request-Id")
+		if len(got[i].ID) != 36 || len(got[i].Token) != 36 || len(requestIDs[i]) != 36 {
+			t.Errorf("got %+v, X-Request-Id %q, want UUIDs", got[i], requestIDs[i])
+		}
+		if got[i].Owner != "taro" || got[i].Age < 18 || got[i].Age > 90 {
+			t.Errorf("got %+v, want owner taro and an age from 18 to 90", got[i])
+		}
+	}
+	if got[0].ID == got[1].ID || got[0].Token == got[1].Token || requestIDs[0] == requestIDs[1] {
+		t.Errorf("UUIDs repeated across requests: %+v %v", got, requestIDs)
+	}
+
+	rec := serve(t, newRequest("GET", "/literal", ""))
+	if !strings.Contains(rec.Body.String(), `"{{randomInt 1 9}}"`) {
+		t.Errorf("without template: %s, want the braces kept", rec.Body.String())
+	}
+}
+
+func TestRequestValuesAreNotExpanded(t *testing.T) {
+	newMockDir(t, map[string]string{
+		"echo.json": `{"headers": {"X-Echo": "{query.q}"}, "body": {"q": "{query.q}", "id": "{uuid}"}}`,
+	})
+	t.Cleanup(roundRobinState.reset)
+
+	for _, q := range []string{"{uuid}", "{now}", "{roundrobin:x,y}", "{body.length}", "{flag.x}"} {
+		rec := serve(t, newRequest("GET", "/echo?q="+url.QueryEscape(q), ""))
+		var got map[string]string
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Fatalf("q=%q: invalid JSON %s: %v", q, rec.Body.String(), err)
+		}
+		if got["q"] != q || rec.Header().Get("X-Echo") != q {
+			t.Errorf("q=%q: body %q, X-Echo %q, want it echoed unchanged", q, got["q"], rec.Header().Get("X-Echo"))
+		}
+		if len(got["id"]) != 36 {
+			t.Errorf("q=%q: id %q, want a UUID", q, got["id"])
+		}
+	}
+
+	roundRobinState.mu.Lock()
+	defer roundRobinState.mu.Unlock()
+	if _, ok := roundRobinState.counters["x,y"]; ok {
+		t.Error("a round-robin counter was created from a request value")
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Scheme clients use to reach the server
+func serverScheme() string {
+	if configTLSCert != "" || configTLSSelfSigned {
+		return "https"
+	}
+	return "http"
+}
+
+// Give all servers the same self-signed certificate, if enabled
+func prepareTLS(servers []*http.Server) error {
+	if !configTLSSelfSigned {
+		return nil
+	}
+	cert, err := selfSignedCert()
+	if err != nil {
+		return fmt.Errorf("generating self-signed certificate: %w", err)
+	}
+	for _, srv := range servers {
+		srv.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+	}
+	return nil
+}
+
+// Listen on the server's address with HTTP or HTTPS, depending on the
+// TLS settings
+func listenAndServe(srv *http.Server) error {
+	ln, err := listen(srv)
+	if err != nil {
+		return err
+	}
+	switch {
+	case configTLSCert != "":
+		return srv.ServeTLS(ln, configTLSCert, configTLSKey)
+	case configTLSSelfSigned:
+		return srv.ServeTLS(ln, "", "")
+	}
+	return srv.Serve(ln)
+}
+
+// In-memory certificate for localhost, valid for a year. Clients have to
+// skip verification (e.g. curl -k) since no CA signed it.
+func selfSignedCert() (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"apimock"}, CommonName: "localhost"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.AddDate(1, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	log.Printf("[apimock] Self-signed certificate SHA-256 fingerprint: %X", sha256.Sum256(der))
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// W3C Trace Context span of a request (enabled with --trace-context)
+type traceSpan struct {
+	traceID  string
+	parentID string // Span id of the caller ("" when the trace was started here)
+	spanID   string // Span id of the mock's response
+	flags    string
+}
+
+type traceContextKey struct{}
+
+// version-traceid-parentid-flags; versions after 00 may append fields
+var traceparentRe = regexp.MustCompile(`^([0-9a-f]{2})-([0-9a-f]{32})-([0-9a-f]{16})-([0-9a-f]{2})(-.*)?$`)
+
+// Parse a traceparent header. Invalid values are ignored as the spec requires.
+func parseTraceparent(h string) (traceSpan, bool) {
+	m := traceparentRe.FindStringSubmatch(strings.TrimSpace(h))
+	if m == nil || m[1] == "ff" || (m[1] == "00" && m[5] != "") {
+		return traceSpan{}, false
+	}
+	if m[2] == strings.Repeat("0", 32) || m[3] == strings.Repeat("0", 16) {
+		return traceSpan{}, false
+	}
+	return traceSpan{traceID: m[2], parentID: m[3], flags: m[4]}, true
+}
+
+func (tc *traceSpan) traceparent() string {
+	return fmt.Sprintf("00-%s-%s-%s", tc.traceID, tc.spanID, tc.flags)
+}
+
+// Continue the incoming trace (or start a new one) with a child span and
+// return the updated traceparent
+func withTraceContext(next http.HandlerFunc) http.HandlerFunc {
+	if !configTraceContext {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		tc, ok := parseTraceparent(r.Header.Get("traceparent"))
+		if !ok {
+			tc = traceSpan{traceID: randomHex(16), flags: "01"}
+		}
+		tc.spanID = randomHex(8)
+
+		w.Header().Set("traceparent", tc.traceparent())
+		if ts := r.Header.Get("tracestate"); ok && ts != "" {
+			w.Header().Set("tracestate", ts)
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), traceContextKey{}, &tc)))
+	}
+}
+
+func requestTrace(r *http.Request) *traceSpan {
+	tc, _ := r.Context().Value(traceContextKey{}).(*traceSpan)
+	return tc
+}
+
+// Replace {trace.id}, {trace.spanId}, {trace.parentId} and {trace.traceparent}
+func (tc *traceSpan) replaceTokens(s string) string {
+	if tc == nil || !strings.Contains(s, "{trace.") {
+		return s
+	}
+	return strings.NewReplacer(
+		"{trace.id}", tc.traceID,
+		"{trace.spanId}", tc.spanID,
+		"{trace.parentId}", tc.parentID,
+		"{trace.traceparent}", tc.traceparent(),
+	).Replace(s)
+}
+
+// n random bytes as lowercase hex (never all zeros)
+func randomHex(n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(randomInt(0, 255))
+	}
+	b[n-1] |= 1
+	return fmt.Sprintf("%x", b)
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Recent requests kept for the dashboard
+const recentRequestsSize = 100
+
+type trafficEntry struct {
+	Time       time.Time `json:"time"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	Route      string    `json:"route"` // Empty if no mock matched
+	Status     int       `json:"status"`
+	Bytes      int64     `json:"bytes"` // Body bytes written (after compression)
+	DurationMs float64   `json:"durationMs"`
+}
+
+// Hits and response sizes of one route (or of all requests)
+type routeStats struct {
+	Count       int64            `json:"count"`
+	Bytes       int64            `json:"bytes"`    // Total body bytes written
+	MaxBytes    int64            `json:"maxBytes"` // Largest response body
+	Statuses    map[string]int64 `json:"statuses"` // Responses per status code
+	LastMethod  string           `json:"lastMethod"`
+	LastStatus  int              `json:"lastStatus"`
+	LastBytes   int64            `json:"lastBytes"`
+	LastRequest time.Time        `json:"lastRequest"`
+}
+
+func (st *routeStats) add(e trafficEntry) {
+	st.Count++
+	st.Bytes += e.Bytes
+	st.MaxBytes = max(st.MaxBytes, e.Bytes)
+	if st.Statuses == nil {
+		st.Statuses = map[string]int64{}
+	}
+	st.Statuses[strconv.Itoa(e.Status)]++
+	st.LastMethod = e.Method
+	st.LastStatus = e.Status
+	st.LastBytes = e.Bytes
+	st.LastRequest = e.Time
+}
+
+// A copy that shares no map with st
+func (st routeStats) clone() routeStats {
+	statuses := make(map[string]int64, len(st.Statuses))
+	for k, v := range st.Statuses {
+		statuses[k] = v
+	}
+	st.Statuses = statuses
+	return st
+}
+
+// Request stats per route and the most recent requests. Kept in memory only.
+type trafficStore struct {
+	mu     sync.Mutex
+	routes map[string]*routeStats
+	total  routeStats     // Every request, also those no mock matched
+	recent []trafficEntry // Ring buffer
+	next   int
+}
+
+var trafficState = &trafficStore{routes: map[string]*routeStats{}}
+
+func (s *trafficStore) record(e trafficEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.total.add(e)
+	if e.Route != "" {
+		st := s.routes[e.Route]
+		if st == nil {
+			st = &routeStats{}
+			s.routes[e.Route] = st
+		}
+		st.add(e)
+	}
+	if len(s.recent) < recentRequestsSize {
+		s.recent = append(s.recent, e)
+	} else {
+		s.recent[s.next] = e
+	}
+	s.next = (s.next + 1) % recentRequestsSize
+}
+
+// Counts per route and recent requests, newest first
+func (s *trafficStore) snapshot() map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	counts := make(map[string]int64, len(s.routes))
+	for k, v := range s.routes {
+		counts[k] = v.Count
+	}
+	recent := make([]trafficEntry, 0, len(s.recent))
+	for i := 1; i <= len(s.recent); i++ {
+		recent = append(recent, s.recent[(s.next-i+recentRequestsSize)%recentRequestsSize])
+	}
+	return map[string]interface{}{"counts": counts, "recent": recent}
+}
+
+// Hits per route (keyed by mock file), for assertions in tests
+func (s *trafficStore) stats() map[string]routeStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := make(map[string]routeStats, len(s.routes))
+	for k, v := range s.routes {
+		stats[k] = v.clone()
+	}
+	return stats
+}
+
+// Stats of all requests together
+func (s *trafficStore) totalStats() routeStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.total.clone()
+}
+
+// Clear the stats per route and in total, keeping the recent requests
+func (s *trafficStore) resetStats() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.routes = map[string]*routeStats{}
+	s.total = routeStats{}
+}
+
+func (s *trafficStore) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.routes = map[string]*routeStats{}
+	s.total = routeStats{}
+	s.recent = nil
+	s.next = 0
+}
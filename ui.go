@@ -0,0 +1,152 @@
+package main
+
+import (
+	"net/http"
+)
+
+// Self-contained dashboard backed by the Admin API
+func serveDashboard(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write([]byte(dashboardHTML))
+}
+
+const dashboardHTML = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>apimock dashboard</title>
+<style>
+body { font-family: system-ui, sans-serif; margin: 1.5em; color: #222; }
+h1 { font-size: 1.4em; }
+h2 { font-size: 1.1em; margin-top: 1.5em; }
+table { border-collapse: collapse; width: 100%; font-size: 0.9em; }
+th, td { text-align: left; padding: 0.25em 0.6em; border-bottom: 1px solid #ddd; }
+td.num { text-align: right; font-variant-numeric: tabular-nums; }
+tr.disabled td { color: #999; }
+.s2 { color: #080; } .s3 { color: #05a; } .s4 { color: #b60; } .s5 { color: #c00; }
+button { font-size: 0.85em; }
+#status { color: #888; font-size: 0.85em; }
+</style>
+</head>
+<body>
+<h1>apimock dashboard <span id="status"></span></h1>
+
+<h2>Routes</h2>
+<table>
+<thead><tr><th>Route</th><th>Requests</th><th></th></tr></thead>
+<tbody id="routes"></tbody>
+</table>
+
+<h2>Feature flags</h2>
+<table>
+<thead><tr><th>Flag</th><th>Value</th><th></th></tr></thead>
+<tbody id="flags"></tbody>
+</table>
+
+<h2>Recent requests <button id="reset">Clear</button></h2>
+<table>
+<thead><tr><th>Time</th><th>Method</th><th>Path</th><th>Route</th><th>Status</th><th>Bytes</th><th>ms</th></tr></thead>
+<tbody id="recent"></tbody>
+</table>
+
+<script>
+const api = "/__apimock/";
+
+function cell(tr, text, cls) {
+  const td = document.createElement("td");
+  td.textContent = text;
+  if (cls) td.className = cls;
+  tr.appendChild(td);
+  return td;
+}
+
+function button(td, label, onclick) {
+  const b = document.createElement("button");
+  b.textContent = label;
+  b.onclick = async () => { await onclick(); refresh(); };
+  td.appendChild(b);
+}
+
+async function post(path, body) {
+  await fetch(api + path, {method: "POST", body: body === undefined ? null : JSON.stringify(body)});
+}
+
+function renderRoutes(routes, counts) {
+  const tbody = document.getElementById("routes");
+  tbody.replaceChildren();
+  for (const r of routes) {
+    const tr = document.createElement("tr");
+    if (r.disabled) tr.className = "disabled";
+    cell(tr, r.id);
+    cell(tr, counts[r.id] || 0, "num");
+    const id = encodeURIComponent(r.id);
+    button(cell(tr, ""), r.disabled ? "Enable" : "Disable",
+      () => post("routes/" + id + (r.disabled ? "/enable" : "/disable")));
+    tbody.appendChild(tr);
+  }
+}
+
+function renderFlags(flags) {
+  const tbody = document.getElementById("flags");
+  tbody.replaceChildren();
+  for (const name of Object.keys(flags).sort()) {
+    const value = flags[name];
+    const tr = document.createElement("tr");
+    cell(tr, name);
+    cell(tr, JSON.stringify(value));
+    const td = cell(tr, "");
+    if (typeof value === "boolean") {
+      button(td, "Toggle", () => post("flags", {[name]: !value}));
+    } else {
+      button(td, "Edit", () => {
+        const input = prompt("JSON value of " + name, JSON.stringify(value));
+        if (input === null) return;
+        try { return post("flags", {[name]: JSON.parse(input)}); } catch (e) { alert("Invalid JSON"); }
+      });
+    }
+    tbody.appendChild(tr);
+  }
+  if (Object.keys(flags).length === 0) {
+    const tr = document.createElement("tr");
+    cell(tr, "No flags (see --flags)");
+    tbody.appendChild(tr);
+  }
+}
+
+function renderRecent(recent) {
+  const tbody = document.getElementById("recent");
+  tbody.replaceChildren();
+  for (const e of recent) {
+    const tr = document.createElement("tr");
+    cell(tr, new Date(e.time).toLocaleTimeString());
+    cell(tr, e.method);
+    cell(tr, e.path);
+    cell(tr, e.route || "-");
+    cell(tr, e.status, "s" + String(e.status)[0]);
+    cell(tr, e.bytes, "num");
+    cell(tr, e.durationMs.toFixed(1), "num");
+    tbody.appendChild(tr);
+  }
+}
+
+async function refresh() {
+  try {
+    const [routes, flags, traffic] = await Promise.all(
+      ["routes", "flags", "requests"].map(p => fetch(api + p).then(r => r.json())));
+    renderRoutes(routes, traffic.counts);
+    renderFlags(flags);
+    renderRecent(traffic.recent);
+    document.getElementById("status").textContent = "updated " + new Date().toLocaleTimeString();
+  } catch (e) {
+    document.getElementById("status").textContent = "apimock is not reachable";
+  }
+}
+
+document.getElementById("reset").onclick = async () => { await post("requests/reset"); refresh(); };
+refresh();
+setInterval(refresh, 2000);
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"time"
+)
+
+// Base URL requests without a mock are forwarded to (nil: answer 404)
+var upstreamURL *url.URL
+
+// Forward a request that matched no mock to the upstream. Headers apimock
+// has already set (e.g. CORS) replace those of the upstream response.
+// If the upstream cannot be reached, the usual 404 is sent. With --record,
+// the response is saved below baseDir.
+func proxyUpstream(w http.ResponseWriter, r *http.Request, baseDir string) {
+	start := time.Now()
+	target := upstreamURL.JoinPath(r.URL.Path)
+	target.RawQuery = r.URL.RawQuery
+
+	proxy := httputil.NewSingleHostReverseProxy(upstreamURL)
+	director := proxy.Director
+	proxy.Director = func(req *http.Request) {
+		director(req)
+		req.Host = upstreamURL.Host // Virtual hosts expect their own name
+	}
+	own := w.Header().Clone()
+	proxy.ModifyResponse = func(resp *http.Response) error {
+		for k := range own {
+			resp.Header.Del(k)
+		}
+		log.Printf("[PROXY] %s %s -> %s %d (%s)", r.Method, r.URL.RequestURI(), target, resp.StatusCode,
+			time.Since(start).Round(time.Millisecond))
+		if *record {
+			recordResponse(r, resp, baseDir)
+		}
+		return nil
+	}
+	proxy.ErrorHandler = func(w http.ResponseWriter, req *http.Request, err error) {
+		log.Printf("[WARNING] [PROXY] %s %s -> %s failed: %v", r.Method, r.URL.RequestURI(), target, err)
+		respondError(w, r, 404, map[string]string{"error": "Not Found"})
+	}
+	proxy.ServeHTTP(w, r)
+}
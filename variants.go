@@ -0,0 +1,140 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Compiled matcher regexps, shared across requests
+var (
+	regexpMu    sync.Mutex
+	regexpCache = map[string]*regexp.Regexp{}
+)
+
+func compileCached(pattern string) (*regexp.Regexp, error) {
+	regexpMu.Lock()
+	defer regexpMu.Unlock()
+	if re, ok := regexpCache[pattern]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	regexpCache[pattern] = re
+	return re, nil
+}
+
+// Per-request state matchers are evaluated against
+type matchContext struct {
+	bucket        string   // A/B bucket assigned by the experiment
+	priorRequests int      // Requests the client made to the route before this one
+	pathParams    []string // Values of the _ segments of the route
+}
+
+// Pick the first variant whose matchers all match the request.
+// Returns false if none matches.
+func selectVariant(mock MockResponse, r *http.Request, filePath string, mc matchContext) (MockResponse, bool) {
+	for _, v := range mock.Variants {
+		// Only entries (array mock files) match on method: variants are
+		// picked after the file's own method check, so theirs is ignored
+		if mock.entries && len(v.Method) > 0 && !methodAllowed(v.Method, r.Method) {
+			continue
+		}
+		if variantMatches(v, r, filePath, mc) {
+			return v, true
+		}
+	}
+	return mock, false
+}
+
+// Whether any variant matches on the client's prior requests
+func variantsCountClients(mock MockResponse) bool {
+	for _, v := range mock.Variants {
+		if v.FirstRequest != nil || v.When != "" && whenUses(v.When, "firstRequest") {
+			return true
+		}
+	}
+	return false
+}
+
+// Whether matching the variants reads the request body
+func variantsReadBody(mock MockResponse) bool {
+	for _, v := range mock.Variants {
+		if v.BodyRegex != "" || v.MatchBody != nil || v.MatchForm != nil || v.When != "" && whenUses(v.When, "body") {
+			return true
+		}
+	}
+	return false
+}
+
+func variantMatches(v MockResponse, r *http.Request, filePath string, mc matchContext) bool {
+	if v.Bucket != "" && v.Bucket != mc.bucket {
+		return false
+	}
+	if v.FirstRequest != nil && *v.FirstRequest != (mc.priorRequests == 0) {
+		return false
+	}
+	if v.BodyRegex != "" {
+		re, err := compileCached(v.BodyRegex)
+		if err != nil {
+			log.Printf("[WARNING] Invalid bodyRegex in %s: %v", filePath, err)
+			return false
+		}
+		body, err := readBody(r)
+		if err != nil {
+			log.Printf("[WARNING] Failed to read request body: %v", err)
+			return false
+		}
+		if !re.Match(body) {
+			return false
+		}
+	}
+	if v.MatchBody != nil && !bodyMatches(v.MatchBody, r) {
+		return false
+	}
+	if v.MatchForm != nil && !formMatches(v.MatchForm, r) {
+		return false
+	}
+	if v.MatchCookies != nil && !cookiesMatch(v.MatchCookies, r) {
+		return false
+	}
+	if len(v.Flags) > 0 && !flagsMatch(v.Flags) {
+		return false
+	}
+	if v.AuthScheme != "" && !strings.EqualFold(v.AuthScheme, authScheme(r)) {
+		return false
+	}
+	if len(v.Query) > 0 && !queryMatches(v.Query, r.URL.Query()) {
+		return false
+	}
+	if v.When != "" {
+		e, err := compileWhen(v.When)
+		if err != nil {
+			log.Printf("[WARNING] Invalid when in %s: %v", filePath, err)
+			return false
+		}
+		if !truthy(e(&whenEnv{r: r, mc: mc})) {
+			return false
+		}
+	}
+	return true
+}
+
+// Scheme of the Authorization header (the word before the first space):
+// "none" if the header is missing or blank, "malformed" if there are no
+// credentials after the scheme
+func authScheme(r *http.Request) string {
+	auth := strings.TrimSpace(r.Header.Get("Authorization"))
+	if auth == "" {
+		return "none"
+	}
+	scheme, credentials, _ := strings.Cut(auth, " ")
+	if strings.TrimSpace(credentials) == "" {
+		return "malformed"
+	}
+	return scheme
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// Current version index of versioned mocks.
+// A per-route value takes precedence over the global one.
+type versionStore struct {
+	mu     sync.Mutex
+	global int
+	routes map[string]int
+}
+
+var versionState = &versionStore{routes: map[string]int{}}
+
+func (s *versionStore) current(route string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if v, ok := s.routes[route]; ok {
+		return v
+	}
+	return s.global
+}
+
+// Advance by one and return the new version (global if route is empty)
+func (s *versionStore) advance(route string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if route == "" {
+		s.global++
+		return s.global
+	}
+	v, ok := s.routes[route]
+	if !ok {
+		v = s.global
+	}
+	s.routes[route] = v + 1
+	return v + 1
+}
+
+func (s *versionStore) set(route string, v int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if route == "" {
+		s.global = v
+	} else {
+		s.routes[route] = v
+	}
+}
+
+func (s *versionStore) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.global = 0
+	s.routes = map[string]int{}
+}
+
+func (s *versionStore) snapshot() map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	routes := make(map[string]int, len(s.routes))
+	for k, v := range s.routes {
+		routes[k] = v
+	}
+	return map[string]interface{}{"global": s.global, "routes": routes}
+}
+
+// Pick the version to serve. The X-Apimock-Version header overrides
+// the shared index for a single request.
+func selectVersion(mock MockResponse, route string, r *http.Request) MockResponse {
+	idx := versionState.current(route)
+	if h := r.Header.Get("X-Apimock-Version"); h != "" {
+		if v, err := strconv.Atoi(h); err == nil && v >= 0 {
+			idx = v
+		}
+	}
+	// Stay on the last version once the end is reached
+	if idx >= len(mock.Versions) {
+		idx = len(mock.Versions) - 1
+	}
+	return mock.Versions[idx]
+}
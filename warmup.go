@@ -0,0 +1,37 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Start of the warm-up periods
+var serverStart = time.Now()
+
+// Time left of a warm-up period of the given length (0 once it is over)
+func warmupRemaining(seconds int) time.Duration {
+	if seconds <= 0 {
+		return 0
+	}
+	left := time.Until(serverStart.Add(time.Duration(seconds) * time.Second))
+	if left < 0 {
+		return 0
+	}
+	return left
+}
+
+// Answer 503 while a warm-up period is running. Returns false if the
+// request was rejected (the response has been written).
+func checkWarmup(w http.ResponseWriter, seconds int) bool {
+	left := warmupRemaining(seconds)
+	if left == 0 {
+		return true
+	}
+	retryAfter := strconv.Itoa(int(left.Seconds() + 0.999))
+	w.Header().Set("Retry-After", retryAfter)
+	writeErrorResponse(w, configWarmupResponse, http.StatusServiceUnavailable,
+		map[string]string{"error": "Service Unavailable", "reason": "warming up"},
+		"{retryAfter}", retryAfter)
+	return false
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Default delay before changes are reported, so that a burst of events
+// (e.g. a git checkout) gives a single reload
+const defaultWatchDebounce = 200 * time.Millisecond
+
+// Directories to watch: the configured watchDirs below the mock directory,
+// or the whole mock directory
+func watchRoots() []string {
+	if len(configWatchDirs) == 0 {
+		return []string{configDir}
+	}
+	var roots []string
+	for _, d := range configWatchDirs {
+		if !filepath.IsLocal(filepath.FromSlash(d)) {
+			log.Printf("[WARNING] Ignoring watchDirs entry '%s': not a subdirectory of the mock directory", d)
+			continue
+		}
+		roots = append(roots, filepath.Join(configDir, filepath.FromSlash(d)))
+	}
+	return roots
+}
+
+// Time to wait after the last change before a burst is reported
+func watchDebounce() time.Duration {
+	if configWatchDebounce > 0 {
+		return time.Duration(configWatchDebounce) * time.Millisecond
+	}
+	return defaultWatchDebounce
+}
+
+// Log the top level of the mock directory
+func logMockTree() {
+	entries, _ := os.ReadDir(configDir)
+	for _, e := range entries {
+		if isIgnored(e.Name(), e.IsDir()) {
+			continue
+		}
+		if e.IsDir() {
+			log.Printf("  └─ 📁 %s/", e.Name())
+		} else if isMockFile(e.Name()) {
+			log.Printf("  ├─ 📄 %s", e.Name())
+		}
+	}
+}
+
+// .apimockrc files the configuration was loaded from (absolute paths)
+func configFilePaths() []string {
+	var paths []string
+	for _, p := range []string{os.ExpandEnv("$HOME/.apimockrc"), ".apimockrc"} {
+		if abs, err := filepath.Abs(p); err == nil {
+			paths = append(paths, abs)
+		}
+	}
+	return paths
+}
+
+type mockWatcher struct {
+	w           *fsnotify.Watcher
+	configFiles []string
+
+	mu            sync.Mutex
+	timer         *time.Timer
+	mockChanges   int
+	configChanged bool
+}
+
+// Watch the mock directory (or the configured watchDirs below it) and the
+// .apimockrc files, and log changes. Mock files are read on every request,
+// so this only reports them; configuration changes need a restart.
+func startWatcher() {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Printf("[WARNING] File watching unavailable (use --watch=false to silence): %v", err)
+		return
+	}
+	mw := &mockWatcher{w: w, configFiles: configFilePaths()}
+
+	for _, root := range watchRoots() {
+		mw.addRecursive(root)
+	}
+	// Directories rather than the files themselves, since editors often
+	// replace a file instead of writing to it
+	for _, p := range mw.configFiles {
+		if err := w.Add(filepath.Dir(p)); err != nil && !os.IsNotExist(err) {
+			log.Printf("[WARNING] Cannot watch %s: %v", filepath.Dir(p), err)
+		}
+	}
+
+	go mw.run()
+}
+
+// Watch dir and every directory below it that is not ignored
+func (mw *mockWatcher) addRecursive(dir string) {
+	filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		if rel, err := filepath.Rel(configDir, path); err == nil && rel != "." && isIgnored(filepath.ToSlash(rel), true) {
+			return filepath.SkipDir
+		}
+		if err := mw.w.Add(path); err != nil {
+			log.Printf("[WARNING] Cannot watch %s: %v", path, err)
+		}
+		return nil
+	})
+}
+
+func (mw *mockWatcher) run() {
+	for {
+		select {
+		case ev, ok := <-mw.w.Events:
+			if !ok {
+				return
+			}
+			mw.handle(ev)
+		case err, ok := <-mw.w.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("[WARNING] File watcher: %v", err)
+		}
+	}
+}
+
+func (mw *mockWatcher) handle(ev fsnotify.Event) {
+	if ev.Has(fsnotify.Chmod) && !ev.Has(fsnotify.Write) {
+		return
+	}
+	abs, _ := filepath.Abs(ev.Name)
+	for _, p := range mw.configFiles {
+		if abs == p {
+			mw.schedule(false, true)
+			return
+		}
+	}
+
+	rel, err := filepath.Rel(configDir, ev.Name)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return // Another file next to a config file
+	}
+	if ev.Has(fsnotify.Create) {
+		if info, err := os.Stat(ev.Name); err == nil && info.IsDir() {
+			if !isIgnored(filepath.ToSlash(rel), true) {
+				mw.addRecursive(ev.Name)
+				mw.schedule(true, false)
+			}
+			return
+		}
+	}
+	// A removed directory cannot be told apart from a file any more
+	if isMockFile(ev.Name) || isDefaultsFile(ev.Name) || ev.Has(fsnotify.Remove) || ev.Has(fsnotify.Rename) {
+		if !isIgnored(filepath.ToSlash(rel), false) {
+			mw.schedule(true, false)
+		}
+	}
+}
+
+// Report changes once no event arrived for the debounce interval
+func (mw *mockWatcher) schedule(mockChange, configChange bool) {
+	mw.mu.Lock()
+	defer mw.mu.Unlock()
+	if mockChange {
+		mw.mockChanges++
+	}
+	mw.configChanged = mw.configChanged || configChange
+
+	if mw.timer != nil {
+		mw.timer.Stop()
+	}
+	mw.timer = time.AfterFunc(watchDebounce(), mw.flush)
+}
+
+func (mw *mockWatcher) flush() {
+	mw.mu.Lock()
+	mockChanges, configChanged := mw.mockChanges, mw.configChanged
+	mw.mockChanges, mw.configChanged = 0, false
+	mw.mu.Unlock()
+
+	if mockChanges > 0 {
+		log.Printf("[apimock] Mock files changed (%d event(s)):", mockChanges)
+		logMockTree()
+	}
+	if configChanged {
+		warnConfigChanged()
+	}
+}
+
+// Whether two dir settings resolve to the same path (URLs are compared
+// as-is)
+func sameDir(a, b string) bool {
+	if a == b {
+		return true
+	}
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
+
+// Compare dir and port of the changed .apimockrc files with the running
+// ones. Settings are only read at startup.
+func warnConfigChanged() {
+	newDir, newPorts := "mock", []listenPort{{Port: "8080"}}
+	for _, p := range configFilePaths() {
+		if _, err := os.Stat(p); err != nil {
+			continue
+		}
+		cfg := readConfigFile(p)
+		if cfg == nil {
+			return
+		}
+		if cfg.Dir != "" {
+			newDir = expandHome(cfg.Dir)
+		}
+		if ports, err := parsePorts(cfg.Port); cfg.Port != nil && err == nil && len(ports) > 0 {
+			newPorts = ports
+		}
+	}
+	changed := false
+	// An extracted archive is served from a temporary directory
+	servingDir := configDir
+	if archiveSource != "" {
+		servingDir = archiveSource
+	}
+	if *mockDir == "" && !sameDir(newDir, servingDir) {
+		log.Printf("[WARNING] .apimockrc changed: dir is now '%s' (serving '%s'); restart apimock to apply", newDir, configDir)
+		changed = true
+	}
+	if len(*port) == 0 && formatPorts(newPorts) != formatPorts(configPorts) {
+		log.Printf("[WARNING] .apimockrc changed: port is now %s (listening on %s); restart apimock to apply", formatPorts(newPorts), formatPorts(configPorts))
+		changed = true
+	}
+	if !changed {
+		log.Printf("[WARNING] .apimockrc changed; settings are read at startup, restart apimock to apply them")
+	}
+}
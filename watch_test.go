@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWarnConfigChangedExpandsEnv(t *testing.T) {
+	dir := newMockDir(t, nil)
+	t.Setenv("MOCK_DIR", dir)
+	t.Setenv("PORT", "9090")
+	rc := filepath.Join(os.Getenv("HOME"), ".apimockrc")
+	setConfig(t, &configPorts, []listenPort{{Port: "9090"}})
+
+	tests := []struct {
+		rc, want string
+	}{
+		{`{"dir": "${MOCK_DIR}", "port": "${PORT}"}`, ""},
+		{`{"dir": "${MOCK_DIR}/other", "port": "${PORT}"}`, "dir is now '" + dir + "/other'"},
+		{`{"dir": "${MOCK_DIR}", "port": "8081"}`, "port is now 8081"},
+	}
+	for _, tt := range tests {
+		if err := os.WriteFile(rc, []byte(tt.rc), 0644); err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		log.SetOutput(&buf)
+		warnConfigChanged()
+		log.SetOutput(os.Stderr)
+
+		changed := strings.Contains(buf.String(), "is now")
+		if tt.want == "" && changed || tt.want != "" && !strings.Contains(buf.String(), tt.want) {
+			t.Errorf("%s: logged %q, want %q", tt.rc, buf.String(), tt.want)
+		}
+	}
+}
@@ -0,0 +1,478 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// A compiled `when` expression of a variant, e.g.
+// query.role == 'admin' && header['X-Env'] == 'prod'
+type whenExpr func(env *whenEnv) interface{}
+
+// A compiled expression with the variables it refers to
+type compiledWhen struct {
+	expr      whenExpr
+	variables map[string]bool
+}
+
+// Compiled expressions, shared across requests
+var (
+	whenMu    sync.Mutex
+	whenCache = map[string]*compiledWhen{}
+)
+
+func compileWhen(src string) (whenExpr, error) {
+	c, err := compileWhenCached(src)
+	if err != nil {
+		return nil, err
+	}
+	return c.expr, nil
+}
+
+// Whether a valid expression refers to the variable name (e.g. body,
+// which needs the request body to be read)
+func whenUses(src, name string) bool {
+	c, err := compileWhenCached(src)
+	return err == nil && c.variables[name]
+}
+
+func compileWhenCached(src string) (*compiledWhen, error) {
+	whenMu.Lock()
+	defer whenMu.Unlock()
+	if c, ok := whenCache[src]; ok {
+		return c, nil
+	}
+	tokens, err := tokenizeWhen(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &whenParser{tokens: tokens, variables: map[string]bool{}}
+	e, err := p.parseOr()
+	if err == nil && p.pos < len(p.tokens) {
+		err = fmt.Errorf("unexpected %q", p.tokens[p.pos].text)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c := &compiledWhen{expr: e, variables: p.variables}
+	whenCache[src] = c
+	return c, nil
+}
+
+// Request attributes an expression is evaluated against
+type whenEnv struct {
+	r  *http.Request
+	mc matchContext
+
+	body       interface{}
+	bodyParsed bool
+}
+
+var whenVariables = map[string]bool{
+	"method": true, "path": true, "auth": true, "bucket": true, "firstRequest": true,
+	"query": true, "header": true, "cookie": true, "flags": true, "params": true, "body": true,
+}
+
+// A map-like variable (query, header, ...) looked up by key
+type whenLookup func(key string) interface{}
+
+func (env *whenEnv) variable(name string) (interface{}, bool) {
+	r := env.r
+	switch name {
+	case "method":
+		return r.Method, true
+	case "path":
+		return r.URL.Path, true
+	case "auth":
+		return authScheme(r), true
+	case "bucket":
+		return env.mc.bucket, true
+	case "firstRequest":
+		return env.mc.priorRequests == 0, true
+	case "query":
+		return whenLookup(func(key string) interface{} {
+			if values, ok := r.URL.Query()[key]; ok && len(values) > 0 {
+				return values[0]
+			}
+			return nil
+		}), true
+	case "header":
+		return whenLookup(func(key string) interface{} {
+			if values := r.Header.Values(key); len(values) > 0 {
+				return values[0]
+			}
+			return nil
+		}), true
+	case "cookie":
+		return whenLookup(func(key string) interface{} {
+			if c, err := r.Cookie(key); err == nil {
+				return c.Value
+			}
+			return nil
+		}), true
+	case "flags":
+		return whenLookup(func(key string) interface{} {
+			v, _ := flagState.get(key)
+			return v
+		}), true
+	case "params":
+		params := make([]interface{}, len(env.mc.pathParams))
+		for i, p := range env.mc.pathParams {
+			params[i] = p
+		}
+		return params, true
+	case "body":
+		return env.requestBody(), true
+	}
+	return nil, false
+}
+
+// The request body decoded as JSON, or the raw text if it is not JSON
+// (nil if it cannot be read)
+func (env *whenEnv) requestBody() interface{} {
+	if !env.bodyParsed {
+		env.bodyParsed = true
+		if v, ok := requestJSON(env.r); ok {
+			env.body = v
+		} else if data, err := readBody(env.r); err == nil {
+			env.body = string(data)
+		}
+	}
+	return env.body
+}
+
+// Member access: obj.key, obj['key'], list[0] (negative indexes count from the end)
+func member(v interface{}, key string) interface{} {
+	switch v := v.(type) {
+	case whenLookup:
+		return v(key)
+	case map[string]interface{}:
+		return v[key]
+	case []interface{}:
+		i, err := strconv.Atoi(key)
+		if err == nil && i < 0 {
+			i += len(v)
+		}
+		if err == nil && i >= 0 && i < len(v) {
+			return v[i]
+		}
+	}
+	return nil
+}
+
+func truthy(v interface{}) bool {
+	switch v := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		return v != ""
+	case float64:
+		return v != 0
+	}
+	return true
+}
+
+func toNumber(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// Equality with the conversions request values need: query and header
+// values are strings, so '2' == 2 and 'true' == true
+func whenEqual(a, b interface{}) bool {
+	if reflect.DeepEqual(a, b) {
+		return true
+	}
+	if a == nil || b == nil {
+		return false
+	}
+	_, aStr := a.(string)
+	_, bStr := b.(string)
+	if !aStr && !bStr {
+		return false
+	}
+	if af, ok := toNumber(a); ok {
+		if bf, ok := toNumber(b); ok {
+			return af == bf
+		}
+	}
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
+
+// Ordering of numbers (or numeric strings), else of strings
+func whenCompare(a, b interface{}) (int, bool) {
+	if af, ok := toNumber(a); ok {
+		if bf, ok := toNumber(b); ok {
+			switch {
+			case af < bf:
+				return -1, true
+			case af > bf:
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+	as, aok := a.(string)
+	bs, bok := b.(string)
+	if aok && bok {
+		return strings.Compare(as, bs), true
+	}
+	return 0, false
+}
+
+type whenToken struct {
+	kind string // "ident", "number", "string" or the operator itself
+	text string
+}
+
+var whenOperators = []string{"&&", "||", "==", "!=", "<=", ">=", "=~", "<", ">", "!", "(", ")", "[", "]", "."}
+
+func tokenizeWhen(src string) ([]whenToken, error) {
+	var tokens []whenToken
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '\'' || c == '"':
+			var sb strings.Builder
+			j := i + 1
+			for ; j < len(src) && src[j] != c; j++ {
+				if src[j] == '\\' && j+1 < len(src) {
+					j++
+				}
+				sb.WriteByte(src[j])
+			}
+			if j >= len(src) {
+				return nil, fmt.Errorf("unterminated string at %d", i)
+			}
+			tokens = append(tokens, whenToken{"string", sb.String()})
+			i = j + 1
+		case c >= '0' && c <= '9' || c == '-' && i+1 < len(src) && src[i+1] >= '0' && src[i+1] <= '9':
+			// After '.', a number is an index (list.0.name), not a fraction
+			index := len(tokens) > 0 && tokens[len(tokens)-1].kind == "."
+			j := i + 1
+			for j < len(src) && (src[j] >= '0' && src[j] <= '9' || src[j] == '.' && !index) {
+				j++
+			}
+			tokens = append(tokens, whenToken{"number", src[i:j]})
+			i = j
+		case c == '_' || unicode.IsLetter(rune(c)):
+			j := i + 1
+			for j < len(src) && (src[j] == '_' || unicode.IsLetter(rune(src[j])) || unicode.IsDigit(rune(src[j]))) {
+				j++
+			}
+			tokens = append(tokens, whenToken{"ident", src[i:j]})
+			i = j
+		default:
+			matched := false
+			for _, op := range whenOperators {
+				if strings.HasPrefix(src[i:], op) {
+					tokens = append(tokens, whenToken{op, op})
+					i += len(op)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("unexpected character %q at %d", c, i)
+			}
+		}
+	}
+	return tokens, nil
+}
+
+// Recursive descent parser. Precedence, lowest first:
+// ||, &&, comparisons (== != < <= > >= =~), unary !, member access
+type whenParser struct {
+	tokens    []whenToken
+	pos       int
+	variables map[string]bool // Variables referred to so far
+}
+
+func (p *whenParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos].kind
+	}
+	return ""
+}
+
+func (p *whenParser) next() (whenToken, error) {
+	if p.pos >= len(p.tokens) {
+		return whenToken{}, fmt.Errorf("unexpected end of expression")
+	}
+	p.pos++
+	return p.tokens[p.pos-1], nil
+}
+
+func (p *whenParser) parseOr() (whenExpr, error) {
+	left, err := p.parseAnd()
+	for err == nil && p.peek() == "||" {
+		p.pos++
+		var right whenExpr
+		if right, err = p.parseAnd(); err == nil {
+			l := left
+			left = func(env *whenEnv) interface{} { return truthy(l(env)) || truthy(right(env)) }
+		}
+	}
+	return left, err
+}
+
+func (p *whenParser) parseAnd() (whenExpr, error) {
+	left, err := p.parseComparison()
+	for err == nil && p.peek() == "&&" {
+		p.pos++
+		var right whenExpr
+		if right, err = p.parseComparison(); err == nil {
+			l := left
+			left = func(env *whenEnv) interface{} { return truthy(l(env)) && truthy(right(env)) }
+		}
+	}
+	return left, err
+}
+
+func (p *whenParser) parseComparison() (whenExpr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	op := p.peek()
+	switch op {
+	case "==", "!=", "<", "<=", ">", ">=", "=~":
+	default:
+		return left, nil
+	}
+	p.pos++
+	right, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	switch op {
+	case "==":
+		return func(env *whenEnv) interface{} { return whenEqual(left(env), right(env)) }, nil
+	case "!=":
+		return func(env *whenEnv) interface{} { return !whenEqual(left(env), right(env)) }, nil
+	case "=~":
+		return func(env *whenEnv) interface{} {
+			v, pattern := left(env), right(env)
+			s, ok := pattern.(string)
+			if v == nil || !ok {
+				return false
+			}
+			re, err := compileCached(s)
+			return err == nil && re.MatchString(fmt.Sprint(v))
+		}, nil
+	}
+	return func(env *whenEnv) interface{} {
+		c, ok := whenCompare(left(env), right(env))
+		if !ok {
+			return false
+		}
+		switch op {
+		case "<":
+			return c < 0
+		case "<=":
+			return c <= 0
+		case ">":
+			return c > 0
+		}
+		return c >= 0
+	}, nil
+}
+
+func (p *whenParser) parseUnary() (whenExpr, error) {
+	if p.peek() == "!" {
+		p.pos++
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return func(env *whenEnv) interface{} { return !truthy(operand(env)) }, nil
+	}
+	return p.parseMember()
+}
+
+func (p *whenParser) parseMember() (whenExpr, error) {
+	e, err := p.parsePrimary()
+	for err == nil && (p.peek() == "." || p.peek() == "[") {
+		var key whenToken
+		if p.peek() == "." {
+			p.pos++
+			if key, err = p.next(); err == nil && key.kind != "ident" && key.kind != "number" {
+				err = fmt.Errorf("unexpected %q after '.'", key.text)
+			}
+		} else {
+			p.pos++
+			if key, err = p.next(); err == nil && key.kind != "string" && key.kind != "number" {
+				err = fmt.Errorf("expected a string or number in [], got %q", key.text)
+			}
+			if err == nil && p.peek() != "]" {
+				err = fmt.Errorf("missing ']'")
+			}
+			p.pos++
+		}
+		if err == nil {
+			obj, k := e, key.text
+			e = func(env *whenEnv) interface{} { return member(obj(env), k) }
+		}
+	}
+	return e, err
+}
+
+func (p *whenParser) parsePrimary() (whenExpr, error) {
+	t, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	switch t.kind {
+	case "string":
+		return func(*whenEnv) interface{} { return t.text }, nil
+	case "number":
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", t.text)
+		}
+		return func(*whenEnv) interface{} { return f }, nil
+	case "(":
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("missing ')'")
+		}
+		p.pos++
+		return e, nil
+	case "ident":
+		switch t.text {
+		case "true", "false":
+			b := t.text == "true"
+			return func(*whenEnv) interface{} { return b }, nil
+		case "null":
+			return func(*whenEnv) interface{} { return nil }, nil
+		}
+		if !whenVariables[t.text] {
+			return nil, fmt.Errorf("unknown variable %q", t.text)
+		}
+		name := t.text
+		p.variables[name] = true
+		return func(env *whenEnv) interface{} {
+			v, _ := env.variable(name)
+			return v
+		}, nil
+	}
+	return nil, fmt.Errorf("unexpected %q", t.text)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestWhen(t *testing.T) {
+	req := newRequest("POST", "/users/42?role=admin&page=2", `{"user": {"name": "Taro", "tags": ["a", "b"]}, "total": 10}`,
+		"X-Env", "prod", "Content-Type", "application/json")
+	mc := matchContext{bucket: "B", pathParams: []string{"42"}}
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		// Precedence: && binds tighter than ||, ! tighter than comparisons
+		{"true || false && false", true},
+		{"(true || false) && false", false},
+		{"!false && true", true},
+		{"!(query.role == 'admin')", false},
+		{"query.role == 'guest' || header['X-Env'] == 'prod' && method == 'POST'", true},
+
+		// Comparisons, with strings from the request compared as numbers
+		{"query.page == 2", true},
+		{"query.page != '2'", false},
+		{"query.page > 1 && query.page <= 2", true},
+		{"params.0 >= 42", true},
+		{"body.total < 9", false},
+		{"'abc' < 'abd'", true},
+		{"path =~ '^/users/[0-9]+$'", true},
+		{"bucket == 'B'", true},
+		{"firstRequest", true},
+		{"body.user.name == 'Taro'", true},
+		{"body.user.tags[-1] == 'b'", true},
+
+		// Missing fields are null and never equal to a value
+		{"query.missing == null", true},
+		{"query.missing == ''", false},
+		{"body.user.age > 0", false},
+		{"body.nothing.deeper == null", true},
+		{"header['X-Missing']", false},
+		{"!cookie.session", true},
+	}
+	for _, tt := range tests {
+		e, err := compileWhen(tt.expr)
+		if err != nil {
+			t.Errorf("compileWhen(%q): %v", tt.expr, err)
+			continue
+		}
+		if got := truthy(e(&whenEnv{r: req, mc: mc})); got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestWhenParseErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"query.role ==",
+		"(true",
+		"true)",
+		"query['role'",
+		"query.role == 'admin",
+		"unknown == 1",
+		"query.role # 1",
+		"query.",
+		"true false",
+	} {
+		if _, err := compileWhen(expr); err == nil {
+			t.Errorf("compileWhen(%q) succeeded, want an error", expr)
+		}
+	}
+}
+
+func TestWhenUsesBody(t *testing.T) {
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{"body.total > 1", true},
+		{"query.q == 'x' || body == 'raw'", true},
+		{"query.body == 'x'", false},
+		{"header['X-Body'] == 'body'", false},
+		{"query.tbody == 1", false},
+		{"body ==", false},
+	}
+	for _, tt := range tests {
+		if got := whenUses(tt.expr, "body"); got != tt.want {
+			t.Errorf("whenUses(%q, body) = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Compiled patterns of file name segments with an embedded "_"
+var segmentPatterns sync.Map
+
+// Compiled "_:REGEX" segments (a segmentRegexp, also for invalid ones)
+var segmentRegexps sync.Map
+
+type segmentRegexp struct {
+	re  *regexp.Regexp
+	err error
+}
+
+// Whether a mock segment constrains its wildcard with a regexp ("_:[0-9]+")
+func isRegexpSegment(mock string) bool {
+	return strings.HasPrefix(mock, "_:") && len(mock) > 2
+}
+
+// Compile the regexp of a "_:REGEX" segment, anchored to match the whole
+// request segment
+func compileSegmentRegexp(mock string) (*regexp.Regexp, error) {
+	if v, ok := segmentRegexps.Load(mock); ok {
+		return v.(segmentRegexp).re, v.(segmentRegexp).err
+	}
+	expr := "^(?:" + mock[2:] + ")$"
+	if configCaseInsensitive {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		err = fmt.Errorf("invalid regexp in path segment %s: %v", mock, err)
+	}
+	v, _ := segmentRegexps.LoadOrStore(mock, segmentRegexp{re, err})
+	return v.(segmentRegexp).re, v.(segmentRegexp).err
+}
+
+// Match a request segment against a "_:REGEX" mock segment, e.g.
+// "_:[0-9]+" matches "42" but not "abc". An invalid regexp matches nothing
+// (validation reports it).
+func matchSegmentRegexp(mock, segment string) bool {
+	re, err := compileSegmentRegexp(mock)
+	return err == nil && re.MatchString(segment)
+}
+
+// Problem with the "_:REGEX" segments of a mock file path (nil if none)
+func checkSegmentRegexps(path string) error {
+	dir, name := filepath.Split(path)
+	name, _ = splitMockName(name)
+	for _, seg := range strings.Split(filepath.ToSlash(dir)+name, "/") {
+		if isRegexpSegment(seg) {
+			if _, err := compileSegmentRegexp(seg); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Whether a mock file segment names a request segment (ignoring case
+// with caseInsensitivePaths)
+func segmentEqual(mock, request string) bool {
+	if configCaseInsensitive {
+		return strings.EqualFold(mock, request)
+	}
+	return mock == request
+}
+
+// Match a request segment against a mock segment with "_" inside it,
+// e.g. "users-_" matches "users-42" and captures "42". Every "_" matches
+// at least one character; with several in one segment ("_-_"), each
+// captures as little as possible from the left and the last one takes
+// the rest. Captures are returned in order.
+func matchSegmentPattern(pattern, segment string) ([]string, bool) {
+	re, ok := segmentPatterns.Load(pattern)
+	if !ok {
+		parts := strings.Split(pattern, "_")
+		for i, p := range parts {
+			parts[i] = regexp.QuoteMeta(p)
+		}
+		expr := "^" + strings.Join(parts, "(.+?)") + "$"
+		if configCaseInsensitive {
+			expr = "(?i)" + expr
+		}
+		re, _ = segmentPatterns.LoadOrStore(pattern, regexp.MustCompile(expr))
+	}
+	m := re.(*regexp.Regexp).FindStringSubmatch(segment)
+	if m == nil {
+		return nil, false
+	}
+	return m[1:], true
+}
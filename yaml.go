@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// YAML mock files (.yaml, .yml) are converted to JSON when read, so they
+// support everything JSON mock files do
+func isYAMLFile(name string) bool {
+	return strings.HasSuffix(name, ".yaml") || strings.HasSuffix(name, ".yml")
+}
+
+// Read a mock file as JSON
+func readMockFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil || !isYAMLFile(path) {
+		return data, err
+	}
+	return yamlToJSON(data)
+}
+
+// Convert a YAML document to JSON, keeping the order of keys
+func yamlToJSON(data []byte) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	if err := writeYAMLNode(&b, &doc); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func writeYAMLNode(b *bytes.Buffer, n *yaml.Node) error {
+	switch n.Kind {
+	case 0: // Empty document
+		b.WriteString("null")
+	case yaml.DocumentNode:
+		if len(n.Content) == 0 {
+			b.WriteString("null")
+			return nil
+		}
+		return writeYAMLNode(b, n.Content[0])
+	case yaml.AliasNode:
+		return writeYAMLNode(b, n.Alias)
+	case yaml.SequenceNode:
+		b.WriteByte('[')
+		for i, c := range n.Content {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			if err := writeYAMLNode(b, c); err != nil {
+				return err
+			}
+		}
+		b.WriteByte(']')
+	case yaml.MappingNode:
+		pairs, err := yamlMappingPairs(n)
+		if err != nil {
+			return err
+		}
+		b.WriteByte('{')
+		for i, p := range pairs {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(quoteJSON(p.key))
+			b.WriteByte(':')
+			if err := writeYAMLNode(b, p.value); err != nil {
+				return err
+			}
+		}
+		b.WriteByte('}')
+	case yaml.ScalarNode:
+		switch n.ShortTag() {
+		case "!!null":
+			b.WriteString("null")
+		case "!!bool", "!!int", "!!float":
+			var v interface{}
+			if err := n.Decode(&v); err != nil {
+				return err
+			}
+			data, err := json.Marshal(v)
+			if err != nil {
+				return fmt.Errorf("line %d: %v", n.Line, err)
+			}
+			b.Write(data)
+		default: // Strings, timestamps, binary data
+			b.WriteString(quoteJSON(n.Value))
+		}
+	}
+	return nil
+}
+
+type yamlPair struct {
+	key   string
+	value *yaml.Node
+}
+
+// Key/value pairs of a mapping in order, with merge keys (<<: *base)
+// resolved. Keys of the mapping itself win over merged ones.
+func yamlMappingPairs(n *yaml.Node) ([]yamlPair, error) {
+	for n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+	if n.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("line %d: only mappings can be merged", n.Line)
+	}
+	explicit := map[string]bool{}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if k := n.Content[i]; k.ShortTag() != "!!merge" {
+			explicit[k.Value] = true
+		}
+	}
+
+	var pairs []yamlPair
+	seen := map[string]bool{}
+	add := func(p yamlPair) {
+		if !seen[p.key] {
+			seen[p.key] = true
+			pairs = append(pairs, p)
+		}
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		k, v := n.Content[i], n.Content[i+1]
+		if k.ShortTag() == "!!merge" {
+			merged := []*yaml.Node{v}
+			if v.Kind == yaml.SequenceNode {
+				merged = v.Content
+			}
+			for _, m := range merged {
+				mp, err := yamlMappingPairs(m)
+				if err != nil {
+					return nil, err
+				}
+				for _, p := range mp {
+					if !explicit[p.key] {
+						add(p)
+					}
+				}
+			}
+			continue
+		}
+		if k.Kind != yaml.ScalarNode {
+			return nil, fmt.Errorf("line %d: keys must be strings", k.Line)
+		}
+		add(yamlPair{k.Value, v})
+	}
+	return pairs, nil
+}
+
+var warnedFormatConflicts sync.Map
+
+// Log once that a YAML file is shadowed by the JSON file of the same route
+func warnFormatConflict(a, b string) {
+	if isYAMLFile(a) == isYAMLFile(b) || isGzipFixture(a) || isGzipFixture(b) {
+		return
+	}
+	if isYAMLFile(a) {
+		a, b = b, a
+	}
+	if _, warned := warnedFormatConflicts.LoadOrStore(b, true); !warned {
+		log.Printf("[WARNING] %s and %s serve the same route; using %s", routeKey(a), routeKey(b), routeKey(a))
+	}
+}